@@ -2,8 +2,8 @@ module github.com/go-tsne/examples/mnist2d
 
 go 1.17
 
-// To run with the local go-tsne uncomment the following line
-// replace github.com/danaugrs/go-tsne/tsne => ../../tsne
+// The examples are built with the local go-tsne, since they use features newer than the required version
+replace github.com/danaugrs/go-tsne/tsne => ../../tsne
 
 require (
 	github.com/danaugrs/go-tsne/tsne v0.0.0-20220306153449-0ee45704632c
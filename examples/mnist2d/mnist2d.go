@@ -5,9 +5,7 @@ package main
 
 import (
 	"fmt"
-	"math/rand"
 	"os"
-	"time"
 
 	"github.com/danaugrs/go-tsne/tsne"
 	"github.com/sjwhitworth/golearn/pca"
@@ -27,9 +25,6 @@ func main() {
 	fmt.Println("Hi! Check the 'output' directory to see the plots generated while t-SNE runs.")
 	fmt.Printf("PCA Components = %v\nPerplexity = %v\nLearning Rate = %v\n\n", pcaComponents, perplexity, learningRate)
 
-	// Initialize the random seed
-	rand.Seed(int64(time.Now().Nanosecond()))
-
 	// Load a subset of MNIST with 2500 records
 	X, Y := LoadMNIST()
 
@@ -24,7 +24,6 @@ import (
 
 	"image"
 	"image/color"
-	"math/rand"
 	"time"
 )
 
@@ -104,9 +103,6 @@ func main() {
 	a.Subscribe(window.OnWindowSize, onResize)
 	onResize("", nil)
 
-	// Initialize the random seed
-	rand.Seed(int64(time.Now().Nanosecond()))
-
 	// Load a subset of MNIST with 2500 records
 	X, Y := LoadMNIST()
 
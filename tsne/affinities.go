@@ -0,0 +1,174 @@
+// Copyright (c) 2018 Daniel Augusto Rizzi Salvadori. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found in the LICENSE file.
+
+package tsne
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+// EmbedAffinities runs t-SNE on precomputed input affinities instead of computing them from data or distances.
+// P is an n by n matrix of non-negative affinities, dense or a *SparseMatrix, such as a similarity graph.
+// It is symmetrized as (P + P')/2 and normalized into a joint probability matrix, ignoring its diagonal.
+// A sparse P is stored in PSparse (regardless of Sparse) and a dense one in P. Perplexity is not used.
+// It returns an error wrapping ErrEmptyInput, ErrTooFewPoints, ErrNotSquare, ErrNonFinite, ErrNegativeAffinity,
+// ErrZeroAffinities, ErrShape (for inconsistent sparse data), ErrDimsOut, ErrMaxIter or ErrConfig if the input or
+// parameters are invalid.
+// Since there is no data to initialize the embedding from, InitPCA is not supported.
+func (tsne *TSNE) EmbedAffinities(P mat.Matrix, stepFunc func(iter int, divergence float64, embedding mat.Matrix) bool) (mat.Matrix, error) {
+
+	return tsne.EmbedAffinitiesContext(context.Background(), P, stepFunc)
+}
+
+// EmbedAffinitiesContext is like EmbedAffinities, but it stops as soon as ctx is done. In that case it returns
+// ctx.Err() along with the embedding optimized so far, which is nil if the optimization had not started.
+func (tsne *TSNE) EmbedAffinitiesContext(ctx context.Context, P mat.Matrix, stepFunc func(iter int, divergence float64, embedding mat.Matrix) bool) (mat.Matrix, error) {
+
+	if err := validateAffinities(P); err != nil {
+		return nil, err
+	}
+	n, _ := P.Dims()
+	if err := tsne.validateParamsWithoutData(n); err != nil {
+		return nil, err
+	}
+	tsne.Y = nil
+	err := tsne.embedAffinities(ctx, P, stepFunc)
+	return tsne.embedding(), err
+}
+
+// embedAffinities symmetrizes and normalizes the affinities P into the joint probabilities and runs t-SNE.
+// It returns ctx.Err() if ctx is done before finishing.
+func (tsne *TSNE) embedAffinities(ctx context.Context, P mat.Matrix, stepFunc func(iter int, divergence float64, embedding mat.Matrix) bool) error {
+
+	tsne.n, _ = P.Dims()
+	tsne.data = nil
+	tsne.beta, tsne.entropy, tsne.unconverged = nil, nil, nil
+	tsne.initRand()
+	start := time.Now()
+	var err error
+	if sparse, ok := P.(*SparseMatrix); ok {
+		tsne.P = nil
+		tsne.PSparse, err = normalizeSparseAffinities(sparse)
+	} else {
+		tsne.P, err = normalizeAffinities(P)
+		tsne.PSparse = nil
+	}
+	if err != nil {
+		return err
+	}
+	tsne.affinityTime = time.Since(start)
+	tsne.initSolution(nil, nil)
+	return tsne.run(ctx, stepFunc)
+}
+
+// validateAffinities verifies that the affinity matrix P is square and only contains finite non-negative values,
+// and that the CSR data of a sparse P is consistent.
+func validateAffinities(P mat.Matrix) error {
+
+	n, d := P.Dims()
+	if n != d {
+		return fmt.Errorf("%w: got %d by %d", ErrNotSquare, n, d)
+	}
+	check := func(i, j int, v float64) error {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return &ElementError{Row: i, Col: j, Value: v, Err: ErrNonFinite}
+		}
+		if v < 0 {
+			return &ElementError{Row: i, Col: j, Value: v, Err: ErrNegativeAffinity}
+		}
+		return nil
+	}
+	if sparse, ok := P.(*SparseMatrix); ok {
+		if err := sparse.validate(); err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			for e := sparse.RowPtr[i]; e < sparse.RowPtr[i+1]; e++ {
+				if err := check(i, sparse.ColIdx[e], sparse.Val[e]); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			if err := check(i, j, P.At(i, j)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// normalizeAffinities returns the joint probability matrix (P + P')/2, with a zero diagonal, normalized to sum to one.
+// As in d2p, its entries are then bounded below by GreaterThanZero. It returns an error wrapping ErrZeroAffinities
+// if P has no positive values off its diagonal.
+func normalizeAffinities(P mat.Matrix) (*mat.Dense, error) {
+
+	n, _ := P.Dims()
+	joint := mat.NewDense(n, n, nil)
+	joint.Add(P, P.T())
+	for i := 0; i < n; i++ {
+		joint.Set(i, i, 0)
+	}
+	sum := mat.Sum(joint)
+	if !(sum > 0) {
+		return nil, ErrZeroAffinities
+	}
+	joint.Apply(func(i, j int, v float64) float64 {
+		return math.Max(v/sum, GreaterThanZero)
+	}, joint)
+	return joint, nil
+}
+
+// normalizeSparseAffinities returns the sparse joint probability matrix (P + P')/2, without diagonal entries,
+// normalized to sum to one. It returns an error wrapping ErrZeroAffinities if P has no positive values off its diagonal.
+func normalizeSparseAffinities(P *SparseMatrix) (*SparseMatrix, error) {
+
+	// Count the entries of each row, including those mirrored from other rows
+	n := P.n
+	rowPtr := make([]int, n+1)
+	for i := 0; i < n; i++ {
+		for _, j := range P.ColIdx[P.RowPtr[i]:P.RowPtr[i+1]] {
+			if i != j {
+				rowPtr[i+1]++
+				rowPtr[j+1]++
+			}
+		}
+	}
+	for i := 0; i < n; i++ {
+		rowPtr[i+1] += rowPtr[i]
+	}
+	// Scatter the entries and their mirrors into the rows
+	colIdx := make([]int, rowPtr[n])
+	val := make([]float64, rowPtr[n])
+	next := make([]int, n)
+	copy(next, rowPtr[:n])
+	for i := 0; i < n; i++ {
+		for e := P.RowPtr[i]; e < P.RowPtr[i+1]; e++ {
+			j := P.ColIdx[e]
+			if i == j {
+				continue
+			}
+			v := P.Val[e] / 2
+			colIdx[next[i]], val[next[i]] = j, v
+			next[i]++
+			colIdx[next[j]], val[next[j]] = i, v
+			next[j]++
+		}
+	}
+	joint := compactRows(n, rowPtr, colIdx, val)
+	sum := floats.Sum(joint.Val)
+	if !(sum > 0) {
+		return nil, ErrZeroAffinities
+	}
+	floats.Scale(1/sum, joint.Val)
+	return joint, nil
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2018 Daniel Augusto Rizzi Salvadori. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found in the LICENSE file.
+
+package tsne
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// TestEmbedAffinities verifies that embedding the affinities computed from distances reproduces the embedding
+// of the distances, and that dense and sparse affinities are symmetrized and normalized alike.
+func TestEmbedAffinities(t *testing.T) {
+
+	cfg := DefaultConfig()
+	cfg.Perplexity = 10
+	cfg.MaxIter = 10 // The optimization amplifies rounding differences, so only the first iterations are compared
+	cfg.Seed = 1
+	fromDistances, _ := New(cfg)
+	Y, err := fromDistances.TryEmbedDistances(SquaredDistanceMatrix(randomData(50, 4)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromAffinities, _ := New(cfg)
+	Yp, err := fromAffinities.EmbedAffinities(fromDistances.P, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !mat.EqualApprox(Y, Yp, 1e-6) {
+		t.Error("embedding of the affinities differs from the embedding of the distances")
+	}
+	// An asymmetric sparse similarity graph, with diagonal entries
+	rnd := rand.New(rand.NewSource(1))
+	n := 30
+	var rowPtr, colIdx []int
+	var val []float64
+	for i := 0; i < n; i++ {
+		rowPtr = append(rowPtr, len(val))
+		for j := 0; j < n; j++ {
+			if rnd.Intn(4) == 0 {
+				colIdx = append(colIdx, j)
+				val = append(val, float64(rnd.Intn(10)))
+			}
+		}
+	}
+	rowPtr = append(rowPtr, len(val))
+	P, err := NewSparseMatrix(n, rowPtr, colIdx, val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dense, err := normalizeAffinities(P)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sparse, err := normalizeSparseAffinities(P)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var total float64
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			if i != j {
+				total += P.At(i, j)
+			}
+		}
+	}
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			var expected float64
+			if i != j {
+				expected = (P.At(i, j) + P.At(j, i)) / (2 * total)
+			}
+			if math.Abs(sparse.At(i, j)-expected) > 1e-12 || math.Abs(dense.At(i, j)-math.Max(expected, GreaterThanZero)) > 1e-12 {
+				t.Fatalf("P(%d, %d) is %v (sparse %v), expected %v", i, j, dense.At(i, j), sparse.At(i, j), expected)
+			}
+		}
+	}
+}
+
+// TestEmbedAffinitiesErrors verifies that invalid affinities and configurations are rejected.
+func TestEmbedAffinitiesErrors(t *testing.T) {
+
+	valid := mat.NewDense(3, 3, []float64{0, 1, 2, 1, 0, 1, 2, 1, 0})
+	for _, c := range []struct {
+		name string
+		P    mat.Matrix
+		init Initialization
+		err  error
+	}{
+		{"not square", mat.NewDense(2, 3, nil), InitRandom, ErrNotSquare},
+		{"NaN", mat.NewDense(2, 2, []float64{0, math.NaN(), 1, 0}), InitRandom, ErrNonFinite},
+		{"negative", mat.NewDense(2, 2, []float64{0, -1, 1, 0}), InitRandom, ErrNegativeAffinity},
+		{"only diagonal", mat.NewDense(2, 2, []float64{1, 0, 0, 1}), InitRandom, ErrZeroAffinities},
+		{"sparse negative", &SparseMatrix{2, []int{0, 1, 2}, []int{1, 0}, []float64{1, -1}}, InitRandom, ErrNegativeAffinity},
+		{"sparse unsorted", &SparseMatrix{2, []int{0, 2, 2}, []int{1, 0}, []float64{1, 1}}, InitRandom, ErrShape},
+		{"sparse empty", &SparseMatrix{2, []int{0, 0, 0}, nil, nil}, InitRandom, ErrZeroAffinities},
+		{"PCA", valid, InitPCA, ErrConfig},
+	} {
+		cfg := DefaultConfig()
+		cfg.Init = c.init
+		tsne, _ := New(cfg)
+		if Y, err := tsne.EmbedAffinities(c.P, nil); !errors.Is(err, c.err) || Y != nil {
+			t.Errorf("%s: got error %v, expected %v", c.name, err, c.err)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright (c) 2018 Daniel Augusto Rizzi Salvadori. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found in the LICENSE file.
+
+package tsne
+
+import (
+	"context"
+	"math"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+// spTree is a space-partitioning tree over the rows of an embedding.
+// Each node splits its cell in half along every dimension, so it is a quadtree for
+// two-dimensional embeddings and an octree for three-dimensional ones.
+type spTree struct {
+	dims         int
+	data         []float64 // Row-major embedding data (shared by all nodes)
+	center       []float64 // Center of the cell
+	halfWidth    []float64 // Half of the cell width along each dimension
+	centerOfMass []float64 // Center of mass of all points in the cell
+	size         int       // Number of points in the cell (including children)
+	indices      []int     // Points stored in this node if it is a leaf
+	children     []*spTree // Child cells (nil if leaf)
+}
+
+// maxTreeDepth bounds the subdivision of cells containing (nearly) coincident points.
+const maxTreeDepth = 64
+
+// newSPTree builds a space-partitioning tree containing all the rows of Y.
+func newSPTree(Y *mat.Dense) *spTree {
+
+	n, d := Y.Dims()
+	raw := Y.RawMatrix()
+	data := raw.Data
+	if raw.Stride != d {
+		data = mat.DenseCopyOf(Y).RawMatrix().Data
+	}
+	// Compute the bounding box of the embedding
+	minY := make([]float64, d)
+	maxY := make([]float64, d)
+	for k := 0; k < d; k++ {
+		minY[k] = math.Inf(1)
+		maxY[k] = math.Inf(-1)
+	}
+	for i := 0; i < n; i++ {
+		for k := 0; k < d; k++ {
+			v := data[i*d+k]
+			minY[k] = math.Min(minY[k], v)
+			maxY[k] = math.Max(maxY[k], v)
+		}
+	}
+	center := make([]float64, d)
+	halfWidth := make([]float64, d)
+	for k := 0; k < d; k++ {
+		center[k] = (minY[k] + maxY[k]) / 2
+		halfWidth[k] = math.Max(maxY[k]-center[k], center[k]-minY[k]) + 1e-5
+	}
+	tree := newSPTreeNode(d, data, center, halfWidth)
+	for i := 0; i < n; i++ {
+		tree.insert(i, 0)
+	}
+	return tree
+}
+
+// newSPTreeNode creates an empty cell with the specified center and half width.
+func newSPTreeNode(dims int, data, center, halfWidth []float64) *spTree {
+
+	return &spTree{
+		dims:         dims,
+		data:         data,
+		center:       center,
+		halfWidth:    halfWidth,
+		centerOfMass: make([]float64, dims),
+	}
+}
+
+// point returns the coordinates of the i-th point.
+func (t *spTree) point(i int) []float64 {
+
+	return t.data[i*t.dims : (i+1)*t.dims]
+}
+
+// insert adds the i-th point to the tree.
+func (t *spTree) insert(i, depth int) {
+
+	p := t.point(i)
+	// Update the center of mass with the new point
+	t.size++
+	for k := 0; k < t.dims; k++ {
+		t.centerOfMass[k] += (p[k] - t.centerOfMass[k]) / float64(t.size)
+	}
+	if t.children == nil {
+		// A leaf holds a single point, or several identical ones
+		if len(t.indices) == 0 || t.samePoint(t.indices[0], i) || depth >= maxTreeDepth {
+			t.indices = append(t.indices, i)
+			return
+		}
+		t.subdivide()
+		for _, j := range t.indices {
+			t.childFor(t.point(j)).insert(j, depth+1)
+		}
+		t.indices = nil
+	}
+	t.childFor(p).insert(i, depth+1)
+}
+
+// samePoint returns whether the i-th and j-th points have identical coordinates.
+func (t *spTree) samePoint(i, j int) bool {
+
+	pi, pj := t.point(i), t.point(j)
+	for k := 0; k < t.dims; k++ {
+		if pi[k] != pj[k] {
+			return false
+		}
+	}
+	return true
+}
+
+// subdivide creates the 2^dims children of the cell.
+func (t *spTree) subdivide() {
+
+	t.children = make([]*spTree, 1<<uint(t.dims))
+	for c := range t.children {
+		center := make([]float64, t.dims)
+		halfWidth := make([]float64, t.dims)
+		for k := 0; k < t.dims; k++ {
+			halfWidth[k] = t.halfWidth[k] / 2
+			if c&(1<<uint(k)) != 0 {
+				center[k] = t.center[k] + halfWidth[k]
+			} else {
+				center[k] = t.center[k] - halfWidth[k]
+			}
+		}
+		t.children[c] = newSPTreeNode(t.dims, t.data, center, halfWidth)
+	}
+}
+
+// childFor returns the child cell that contains the specified point.
+func (t *spTree) childFor(p []float64) *spTree {
+
+	c := 0
+	for k := 0; k < t.dims; k++ {
+		if p[k] > t.center[k] {
+			c |= 1 << uint(k)
+		}
+	}
+	return t.children[c]
+}
+
+// repulsiveForces accumulates into neg the unnormalized repulsive forces exerted on the i-th point for the kernel tk,
+// approximating cells that are small and far enough away (as determined by theta) by their center of mass.
+// It returns the contribution of the i-th point to the normalization term of Q.
+func (t *spTree) repulsiveForces(i int, theta float64, tk tKernel, neg []float64) float64 {
+
+	if t.size == 0 {
+		return 0
+	}
+	// Points in a leaf other than the i-th point itself
+	count := t.size
+	if t.children == nil {
+		for _, j := range t.indices {
+			if j == i {
+				count--
+			}
+		}
+		if count == 0 {
+			return 0
+		}
+	}
+	p := t.point(i)
+	var dist2, maxWidth float64
+	for k := 0; k < t.dims; k++ {
+		diff := p[k] - t.centerOfMass[k]
+		dist2 += diff * diff
+		maxWidth = math.Max(maxWidth, 2*t.halfWidth[k])
+	}
+	if t.children == nil || maxWidth < theta*math.Sqrt(dist2) {
+		// Summarize the cell by its center of mass
+		q, f := tk.at(dist2)
+		mult := float64(count) * q
+		sumQ := mult
+		mult *= f
+		for k := 0; k < t.dims; k++ {
+			neg[k] += mult * (p[k] - t.centerOfMass[k])
+		}
+		return sumQ
+	}
+	var sumQ float64
+	for _, child := range t.children {
+		sumQ += child.repulsiveForces(i, theta, tk, neg)
+	}
+	return sumQ
+}
+
+// bhRepulsiveForces computes the unnormalized repulsive forces on every point of Y into neg
+// using the Barnes-Hut approximation, traversing the tree for each point in parallel. It returns the normalization term of Q.
+func (tsne *TSNE) bhRepulsiveForces(Y *mat.Dense, neg []float64) float64 {
+
+	n, d := Y.Dims()
+	tree := newSPTree(Y)
+	tk := tsne.outputKernel()
+	rowSumQ := make([]float64, n)
+	parallelFor(context.Background(), n, tsne.workers(), func(w, i int) {
+		rowSumQ[i] = tree.repulsiveForces(i, tsne.Theta, tk, neg[i*d:(i+1)*d])
+	})
+	return floats.Sum(rowSumQ)
+}
@@ -0,0 +1,49 @@
+// Copyright (c) 2018 Daniel Augusto Rizzi Salvadori. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found in the LICENSE file.
+
+package tsne
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// randomData returns an n by d matrix of normally distributed values.
+func randomData(n, d int) *mat.Dense {
+
+	rnd := rand.New(rand.NewSource(1))
+	X := mat.NewDense(n, d, nil)
+	X.Apply(func(i, j int, v float64) float64 {
+		return rnd.NormFloat64()
+	}, X)
+	return X
+}
+
+// TestBarnesHutGradient verifies that the Barnes-Hut gradient matches the exact gradient
+// when (practically) no cells are summarized, for both 2D and 3D embeddings.
+func TestBarnesHutGradient(t *testing.T) {
+
+	X := randomData(60, 5)
+	for _, dimsOut := range []int{2, 3} {
+		tsne := NewTSNE(dimsOut, 10, 100, 1, false)
+		tsne.n, _ = X.Dims()
+		tsne.d2p(context.Background(), SquaredDistanceMatrix(X), EntropyTolerance, tsne.Perplexity)
+		tsne.initRand()
+		tsne.initSolution(X, nil)
+		tsne.Y.Scale(1e3, tsne.Y)
+		exactDiv := tsne.costGradient(tsne.P, tsne.Y, 1)
+		exactGrad := mat.DenseCopyOf(tsne.dCdY)
+		tsne.Theta = 1e-12
+		bhDiv := tsne.splitCostGradient(tsne.Y, 1)
+		if math.Abs(exactDiv-bhDiv) > 1e-6 {
+			t.Errorf("%dD: Barnes-Hut divergence %v, expected %v", dimsOut, bhDiv, exactDiv)
+		}
+		if !mat.EqualApprox(exactGrad, tsne.dCdY, 1e-9) {
+			t.Errorf("%dD: Barnes-Hut gradient does not match the exact gradient", dimsOut)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+// Copyright (c) 2018 Daniel Augusto Rizzi Salvadori. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found in the LICENSE file.
+
+package tsne
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"time"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// checkpointVersion is the version of the checkpoint format written by Save.
+const checkpointVersion = 1
+
+// checkpoint is the serialized state of a t-SNE embedding.
+// Dense matrices are stored in the binary format of gonum's mat.Dense.MarshalBinary.
+type checkpoint struct {
+	Version          int
+	Config           Config
+	Metric           string `json:",omitempty"` // Name of the metric, if provided by this package
+	N                int
+	Iteration        int
+	History          []float64  `json:",omitempty"` // Divergence at each iteration
+	StopReason       StopReason `json:",omitempty"`
+	Beta             []float64  `json:",omitempty"` // Precision of the Gaussian kernel of each datapoint
+	Entropy          []float64  `json:",omitempty"` // Entropy of the conditional distribution of each datapoint
+	Unconverged      []int      `json:",omitempty"`
+	AffinityTime     time.Duration
+	OptimizationTime time.Duration
+	RandDraws        uint64  // Number of values drawn from the random number generator seeded with Config.Seed
+	ForestSeeds      []int64 `json:",omitempty"` // Seeds of the random projection trees used to find the nearest neighbors
+	PlogP            float64
+	P                []byte            `json:",omitempty"`
+	PSparse          *sparseCheckpoint `json:",omitempty"`
+	Y                []byte
+	Velocity         []byte
+	Gains            []byte
+	Data             []byte `json:",omitempty"`
+	InitialY         []byte `json:",omitempty"` // Initial embedding provided for InitCustom
+}
+
+// sparseCheckpoint is the serialized form of a SparseMatrix.
+type sparseCheckpoint struct {
+	N      int
+	RowPtr []int
+	ColIdx []int
+	Val    []float64
+}
+
+// Save writes the state of the embedding to w as JSON, so that it can be restored with Load:
+// the configuration, the input affinities, the embedding and optimizer state, the iteration counter,
+// the state of the random number generator, and the seeds of the random projection trees (if any).
+// The initial embedding provided for InitCustom is saved too, but not a custom random number generator (Rand)
+// or metric (other than those provided by this package).
+func (tsne *TSNE) Save(w io.Writer) error {
+
+	if tsne.Y == nil {
+		return ErrNotEmbedded
+	}
+	cp := checkpoint{
+		Version:          checkpointVersion,
+		Config:           tsne.Config,
+		N:                tsne.n,
+		Iteration:        tsne.iter,
+		History:          tsne.history,
+		StopReason:       tsne.stopReason,
+		Beta:             tsne.beta,
+		Entropy:          tsne.entropy,
+		Unconverged:      tsne.unconverged,
+		AffinityTime:     tsne.affinityTime,
+		OptimizationTime: tsne.optimizationTime,
+		PlogP:            tsne.PlogP,
+		ForestSeeds:      tsne.forestSeeds,
+	}
+	if P := tsne.PSparse; P != nil {
+		cp.PSparse = &sparseCheckpoint{N: P.n, RowPtr: P.RowPtr, ColIdx: P.ColIdx, Val: P.Val}
+	}
+	if m, ok := tsne.Metric.(builtinMetric); ok {
+		cp.Metric = m.String()
+	}
+	if tsne.src != nil {
+		cp.RandDraws = tsne.src.draws
+	}
+	var initialY *mat.Dense
+	if tsne.InitialY != nil {
+		initialY = mat.DenseCopyOf(tsne.InitialY)
+	}
+	var err error
+	for _, m := range []struct {
+		dst *[]byte
+		src *mat.Dense
+	}{{&cp.P, tsne.P}, {&cp.Y, tsne.Y}, {&cp.Velocity, tsne.Velocity}, {&cp.Gains, tsne.Gains}, {&cp.Data, tsne.data}, {&cp.InitialY, initialY}} {
+		if m.src != nil {
+			if *m.dst, err = m.src.MarshalBinary(); err != nil {
+				return err
+			}
+		}
+	}
+	return json.NewEncoder(w).Encode(&cp)
+}
+
+// Load reads the state of an embedding written by Save, and returns a TSNE that can resume the optimization
+// exactly where it stopped (see Resume). It returns an error wrapping ErrCheckpoint if the state is invalid.
+func Load(r io.Reader) (*TSNE, error) {
+
+	var cp checkpoint
+	if err := json.NewDecoder(r).Decode(&cp); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCheckpoint, err)
+	}
+	if cp.Version != checkpointVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrCheckpoint, cp.Version)
+	}
+	tsne := &TSNE{
+		Config:           cp.Config,
+		n:                cp.N,
+		iter:             cp.Iteration,
+		history:          cp.History,
+		stopReason:       cp.StopReason,
+		beta:             cp.Beta,
+		entropy:          cp.Entropy,
+		unconverged:      cp.Unconverged,
+		affinityTime:     cp.AffinityTime,
+		optimizationTime: cp.OptimizationTime,
+		PlogP:            cp.PlogP,
+		forestSeeds:      cp.ForestSeeds,
+	}
+	if (cp.Beta != nil && len(cp.Beta) != cp.N) || (cp.Entropy != nil && len(cp.Entropy) != cp.N) {
+		return nil, fmt.Errorf("%w: calibration of %d points, expected %d", ErrCheckpoint, len(cp.Beta), cp.N)
+	}
+	if cp.Metric != "" {
+		if tsne.Metric = metricByName(cp.Metric); tsne.Metric == nil {
+			return nil, fmt.Errorf("%w: unknown metric %q", ErrCheckpoint, cp.Metric)
+		}
+	}
+	if P := cp.PSparse; P != nil {
+		tsne.PSparse = &SparseMatrix{n: P.N, RowPtr: P.RowPtr, ColIdx: P.ColIdx, Val: P.Val}
+		if err := tsne.PSparse.validate(); err != nil || P.N != cp.N {
+			return nil, fmt.Errorf("%w: invalid sparse P", ErrCheckpoint)
+		}
+	}
+	var initialY *mat.Dense
+	for _, m := range []struct {
+		dst  **mat.Dense
+		src  []byte
+		rows int
+		cols int
+	}{
+		{&tsne.P, cp.P, cp.N, cp.N},
+		{&tsne.Y, cp.Y, cp.N, cp.Config.DimsOut},
+		{&tsne.Velocity, cp.Velocity, cp.N, cp.Config.DimsOut},
+		{&tsne.Gains, cp.Gains, cp.N, cp.Config.DimsOut},
+		{&tsne.data, cp.Data, cp.N, -1},
+		{&initialY, cp.InitialY, cp.N, cp.Config.DimsOut},
+	} {
+		if m.src == nil {
+			continue
+		}
+		*m.dst = new(mat.Dense)
+		if err := (*m.dst).UnmarshalBinary(m.src); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrCheckpoint, err)
+		}
+		if r, c := (*m.dst).Dims(); r != m.rows || (m.cols >= 0 && c != m.cols) {
+			return nil, fmt.Errorf("%w: matrix is %d by %d, expected %d by %d", ErrCheckpoint, r, c, m.rows, m.cols)
+		}
+	}
+	if initialY != nil {
+		tsne.InitialY = initialY
+	}
+	if tsne.Y == nil || tsne.Velocity == nil || tsne.Gains == nil || (tsne.P == nil) == (tsne.PSparse == nil) ||
+		(tsne.Init == InitCustom && tsne.InitialY == nil) {
+		return nil, fmt.Errorf("%w: missing matrices", ErrCheckpoint)
+	}
+	tsne.dCdY = mat.NewDense(tsne.n, tsne.DimsOut, nil)
+	// Restore the random number generator by replaying its draws
+	tsne.src = newCountingSource(tsne.Seed)
+	for tsne.src.draws < cp.RandDraws {
+		tsne.src.Uint64()
+	}
+	tsne.rng = rand.New(tsne.src)
+	return tsne, nil
+}
+
+// Resume continues the optimization of an embedding from its current iteration until MaxIter iterations
+// have been performed or a stopping rule is satisfied (MaxIter can be increased to continue a finished embedding). Like EmbedDataContext,
+// it stops as soon as ctx is done, returning the embedding optimized so far along with ctx.Err().
+func (tsne *TSNE) Resume(ctx context.Context, stepFunc func(iter int, divergence float64, embedding mat.Matrix) bool) (mat.Matrix, error) {
+
+	if tsne.Y == nil {
+		return nil, ErrNotEmbedded
+	}
+	if err := tsne.Config.Validate(); err != nil {
+		return nil, err
+	}
+	err := tsne.run(ctx, stepFunc)
+	return tsne.Y, err
+}
+
+// countingSource is a source of random numbers that counts the values drawn from it,
+// so that its state can be restored by replaying them.
+type countingSource struct {
+	src   rand.Source64
+	draws uint64
+}
+
+// newCountingSource returns a new counting source initialized with the specified seed.
+func newCountingSource(seed int64) *countingSource {
+
+	return &countingSource{src: rand.NewSource(seed).(rand.Source64)}
+}
+
+// Int63 returns a non-negative pseudo-random 63-bit integer.
+func (s *countingSource) Int63() int64 {
+
+	s.draws++
+	return s.src.Int63()
+}
+
+// Uint64 returns a pseudo-random 64-bit integer.
+func (s *countingSource) Uint64() uint64 {
+
+	s.draws++
+	return s.src.Uint64()
+}
+
+// Seed reinitializes the source with the specified seed.
+func (s *countingSource) Seed(seed int64) {
+
+	s.draws = 0
+	s.src.Seed(seed)
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2018 Daniel Augusto Rizzi Salvadori. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found in the LICENSE file.
+
+package tsne
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// TestCheckpoint verifies that an embedding saved and loaded midway resumes exactly where it stopped.
+func TestCheckpoint(t *testing.T) {
+
+	X := randomData(30, 4)
+	initialY := randomData(30, 2)
+	for _, c := range []struct {
+		sparse bool
+		init   Initialization
+	}{{false, InitRandom}, {true, InitRandom}, {false, InitCustom}} {
+		sparse := c.sparse
+		newTSNE := func() *TSNE {
+			tsne := NewTSNE(2, 5, 100, 30, false)
+			tsne.Sparse = sparse
+			tsne.Init = c.init
+			if c.init == InitCustom {
+				tsne.InitialY = initialY
+			}
+			tsne.Seed = 7
+			tsne.ExaggerationIters = 10
+			tsne.MomentumSwitchIter = 20
+			return tsne
+		}
+		expected := newTSNE().EmbedData(X, nil)
+		// Stop after 15 iterations and save
+		tsne := newTSNE()
+		tsne.EmbedData(X, func(iter int, divergence float64, embedding mat.Matrix) bool {
+			return iter == 14
+		})
+		var buf bytes.Buffer
+		if err := tsne.Save(&buf); err != nil {
+			t.Fatal(err)
+		}
+		// Load and resume
+		loaded, err := Load(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if loaded.Iteration() != 15 {
+			t.Errorf("loaded iteration %d, expected 15", loaded.Iteration())
+		}
+		Y, err := loaded.Resume(context.Background(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !mat.Equal(Y, expected) {
+			t.Errorf("sparse=%v, init %v: resumed embedding differs from the uninterrupted one", sparse, c.init)
+		}
+	}
+}
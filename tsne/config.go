@@ -0,0 +1,214 @@
+// Copyright (c) 2018 Daniel Augusto Rizzi Salvadori. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found in the LICENSE file.
+
+package tsne
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Default values of the configuration parameters, as returned by DefaultConfig.
+const (
+	DefaultDimsOut            = 2
+	DefaultPerplexity         = 30
+	DefaultLearningRate       = 200
+	DefaultMaxIter            = 1000
+	DefaultInitialMomentum    = 0.5
+	DefaultFinalMomentum      = 0.8
+	DefaultMomentumSwitchIter = 250
+	DefaultMinGain            = 0.01
+	DefaultExaggeration       = 12
+	DefaultExaggerationIters  = 250
+
+	DefaultFFTInterpolationPoints = 3
+	DefaultFFTMinIntervals        = 50
+	DefaultFFTIntervalSize        = 1
+
+	DefaultRPTrees = 20
+)
+
+// AutoDegreesOfFreedom can be used as Config.DegreesOfFreedom to use the kernel of parametric t-SNE (van der Maaten, 2009):
+// the Student-t kernel (1 + |yi-yj|²/ν)^-(ν+1)/2 with ν = DimsOut - 1 degrees of freedom (but at least one).
+const AutoDegreesOfFreedom = -1
+
+// Config holds the parameters of a t-SNE dimensionality reductor.
+// Use DefaultConfig to obtain a Config with the default values, modify it as needed, and pass it to New.
+type Config struct {
+	DimsOut      int     // Number of dimensions in the low dimensional map
+	Perplexity   float64 // Perplexity target for the Gaussian kernels in high dimension
+	LearningRate float64 // Gradient descent learning rate
+	MaxIter      int     // Max number of gradient descent iterations
+	Verbose      bool    // If true and Logger is nil, then TSNE outputs progress data to stdout
+
+	// Logger, if not nil, receives the progress messages (see Logger), regardless of Verbose.
+	Logger Logger `json:"-"`
+
+	// DegreesOfFreedom is the degrees of freedom α of the heavy-tailed kernel (1 + |yi-yj|²/α)^-α between the points of the
+	// embedding. Values below 1 give heavier tails, which reveal finer cluster structure, while larger values approach SNE.
+	// If zero, the Student-t kernel with one degree of freedom of classic t-SNE is used.
+	// If AutoDegreesOfFreedom, the Student-t kernel of parametric t-SNE with DimsOut - 1 degrees of freedom is used instead.
+	DegreesOfFreedom float64
+
+	// Method selects how the gradient is computed. By default (MethodAuto), it is computed exactly unless Theta is positive.
+	Method GradientMethod
+
+	// Theta controls the accuracy of the Barnes-Hut approximation of the gradient (typically 0.5).
+	// Larger values are faster but less accurate. If zero (and Method is MethodAuto), the exact O(n²) gradient is computed.
+	// The Barnes-Hut approximation is intended for two- and three-dimensional embeddings.
+	Theta float64
+
+	// Parameters of the FFT-accelerated interpolation (MethodFFT). The embedding is covered by a grid with
+	// at least FFTMinIntervals intervals per dimension, each at most FFTIntervalSize wide, and each containing
+	// FFTInterpolationPoints interpolation nodes. More intervals and nodes are slower but more accurate.
+	FFTInterpolationPoints int
+	FFTMinIntervals        int
+	FFTIntervalSize        float64
+
+	// Sparse indicates whether the input affinities are computed only between each point and its
+	// 3·perplexity nearest neighbors and stored in PSparse instead of P, avoiding n by n storage.
+	Sparse bool
+
+	// Neighbors selects how the nearest neighbors are found for sparse affinities computed by EmbedData.
+	// By default (NeighborsAuto), they are found exactly, with a vantage-point tree if the metric is known to support it.
+	// NeighborsVPTree also uses the tree for custom metrics, which must then satisfy the triangle inequality.
+	// NeighborsRPForest finds them approximately with RPTrees random projection trees, whose leaves have at most
+	// RPLeafSize datapoints (at least twice the number of neighbors plus one, which is used if it is zero).
+	// The trees are drawn from the random number generator of the embedding (see Seed).
+	// More trees and larger leaves find more of the true neighbors, but are slower.
+	Neighbors  NeighborMethod
+	RPTrees    int
+	RPLeafSize int
+
+	// Optimizer parameters. The momentum is InitialMomentum for the first MomentumSwitchIter iterations
+	// and FinalMomentum afterwards. The step of each parameter is scaled by an adaptive gain
+	// (delta-bar-delta), which is never allowed to fall below MinGain.
+	InitialMomentum    float64
+	FinalMomentum      float64
+	MomentumSwitchIter int
+	MinGain            float64
+
+	// Early exaggeration. During the first ExaggerationIters iterations, the attractive forces (i.e. P)
+	// are multiplied by Exaggeration, which helps clusters form and separate. The divergence reported to the
+	// step function is always computed with the unexaggerated P.
+	Exaggeration      float64
+	ExaggerationIters int
+
+	// Stopping rules, which only apply after the early exaggeration phase and are disabled if zero.
+	// The optimization stops before MaxIter iterations if the norm of the gradient falls below MinGradNorm,
+	// if the divergence improved by less than a fraction MinRelImprovement of its value over the last
+	// ImprovementWindow iterations, or if it has not improved on its best value for MaxIterWithoutProgress iterations.
+	// The reason why the optimization stopped is reported by TSNE.StopReason.
+	MinGradNorm            float64
+	MinRelImprovement      float64
+	ImprovementWindow      int
+	MaxIterWithoutProgress int
+
+	// Workers is the number of goroutines used to calibrate the input affinities and to compute the gradient.
+	// If zero, GOMAXPROCS goroutines are used. The results do not depend on the number of workers.
+	Workers int
+
+	// Metric is the distance used by EmbedData (and Transform) to compare datapoints.
+	// Its values are used in place of the squared euclidean distances of classic t-SNE, which is what
+	// the default (nil) uses. Any of the metrics provided by this package or a MetricFunc can be used.
+	Metric Metric `json:"-"`
+
+	// Init selects how the embedding is initialized (InitRandom by default).
+	// If it is InitCustom, InitialY must hold the n by DimsOut initial embedding.
+	Init     Initialization
+	InitialY mat.Matrix `json:"-"`
+
+	// Seed initializes the random number generator used by each embedding, so that embedding the same data
+	// with the same configuration and seed yields identical results. If zero, a seed is picked from the
+	// current time when embedding, and stored in Seed so that the embedding can be reproduced.
+	Seed int64
+
+	// Rand, if not nil, is used as the random number generator instead of one initialized with Seed.
+	// It is not safe to share it between concurrent embeddings.
+	Rand *rand.Rand `json:"-"`
+}
+
+// DefaultConfig returns the default configuration: a two-dimensional embedding with perplexity 30,
+// learning rate 200 and 1000 iterations, computed exactly (without Barnes-Hut nor sparse affinities),
+// with momentum 0.5 switching to 0.8 at iteration 250, minimum gain 0.01,
+// and early exaggeration by a factor of 12 during the first 250 iterations.
+// If MethodFFT is selected, it uses 3 interpolation nodes per interval, and intervals at most 1 wide (at least 50 per dimension).
+// If NeighborsRPForest is selected, it uses 20 random projection trees.
+func DefaultConfig() Config {
+
+	return Config{
+		DimsOut:            DefaultDimsOut,
+		Perplexity:         DefaultPerplexity,
+		LearningRate:       DefaultLearningRate,
+		MaxIter:            DefaultMaxIter,
+		InitialMomentum:    DefaultInitialMomentum,
+		FinalMomentum:      DefaultFinalMomentum,
+		MomentumSwitchIter: DefaultMomentumSwitchIter,
+		MinGain:            DefaultMinGain,
+		Exaggeration:       DefaultExaggeration,
+		ExaggerationIters:  DefaultExaggerationIters,
+
+		FFTInterpolationPoints: DefaultFFTInterpolationPoints,
+		FFTMinIntervals:        DefaultFFTMinIntervals,
+		FFTIntervalSize:        DefaultFFTIntervalSize,
+
+		RPTrees: DefaultRPTrees,
+	}
+}
+
+// Validate verifies that the configuration parameters are valid.
+// It returns an error wrapping ErrDimsOut, ErrMaxIter, ErrPerplexity or ErrConfig otherwise.
+// The perplexity is further required to be less than the number of datapoints when embedding.
+func (cfg Config) Validate() error {
+
+	if cfg.DimsOut < 1 {
+		return fmt.Errorf("%w: got %d", ErrDimsOut, cfg.DimsOut)
+	}
+	if cfg.MaxIter < 0 {
+		return fmt.Errorf("%w: got %d", ErrMaxIter, cfg.MaxIter)
+	}
+	if !(cfg.Perplexity > 0) || math.IsInf(cfg.Perplexity, 1) {
+		return fmt.Errorf("%w: got %v", ErrPerplexity, cfg.Perplexity)
+	}
+	checks := []struct {
+		ok   bool
+		what string
+		val  interface{}
+	}{
+		{cfg.LearningRate > 0 && !math.IsInf(cfg.LearningRate, 1), "LearningRate must be positive", cfg.LearningRate},
+		{cfg.DegreesOfFreedom >= 0 && !math.IsInf(cfg.DegreesOfFreedom, 1) || cfg.DegreesOfFreedom == AutoDegreesOfFreedom, "DegreesOfFreedom must not be negative", cfg.DegreesOfFreedom},
+		{cfg.Method >= MethodAuto && cfg.Method <= MethodFFT, "Method is not a valid gradient method", cfg.Method},
+		{cfg.Theta >= 0 && !math.IsInf(cfg.Theta, 1), "Theta must not be negative", cfg.Theta},
+		{cfg.Method != MethodBarnesHut || cfg.Theta > 0, "Theta must be positive for MethodBarnesHut", cfg.Theta},
+		{cfg.Method != MethodFFT || cfg.DimsOut <= 2, "MethodFFT only supports one- and two-dimensional embeddings", cfg.DimsOut},
+		{cfg.Method != MethodFFT || cfg.FFTInterpolationPoints > 0, "FFTInterpolationPoints must be positive", cfg.FFTInterpolationPoints},
+		{cfg.Method != MethodFFT || cfg.FFTMinIntervals > 0, "FFTMinIntervals must be positive", cfg.FFTMinIntervals},
+		{cfg.Method != MethodFFT || cfg.FFTIntervalSize > 0 && !math.IsInf(cfg.FFTIntervalSize, 1), "FFTIntervalSize must be positive", cfg.FFTIntervalSize},
+		{cfg.Neighbors >= NeighborsAuto && cfg.Neighbors <= NeighborsRPForest, "Neighbors is not a valid neighbor search method", cfg.Neighbors},
+		{cfg.Neighbors != NeighborsVPTree || !isBuiltinMetric(cfg.Metric) || isTreeMetric(cfg.Metric), "NeighborsVPTree requires a metric satisfying the triangle inequality", cfg.Metric},
+		{cfg.Neighbors != NeighborsRPForest || cfg.RPTrees > 0, "RPTrees must be positive", cfg.RPTrees},
+		{cfg.RPLeafSize >= 0, "RPLeafSize must not be negative", cfg.RPLeafSize},
+		{cfg.InitialMomentum >= 0 && cfg.InitialMomentum < 1, "InitialMomentum must be in [0, 1)", cfg.InitialMomentum},
+		{cfg.FinalMomentum >= 0 && cfg.FinalMomentum < 1, "FinalMomentum must be in [0, 1)", cfg.FinalMomentum},
+		{cfg.MomentumSwitchIter >= 0, "MomentumSwitchIter must not be negative", cfg.MomentumSwitchIter},
+		{cfg.MinGain >= 0 && !math.IsInf(cfg.MinGain, 1), "MinGain must not be negative", cfg.MinGain},
+		{cfg.Exaggeration > 0 && !math.IsInf(cfg.Exaggeration, 1), "Exaggeration must be positive", cfg.Exaggeration},
+		{cfg.ExaggerationIters >= 0, "ExaggerationIters must not be negative", cfg.ExaggerationIters},
+		{cfg.MinGradNorm >= 0, "MinGradNorm must not be negative", cfg.MinGradNorm},
+		{cfg.MinRelImprovement >= 0, "MinRelImprovement must not be negative", cfg.MinRelImprovement},
+		{cfg.ImprovementWindow >= 0, "ImprovementWindow must not be negative", cfg.ImprovementWindow},
+		{cfg.MaxIterWithoutProgress >= 0, "MaxIterWithoutProgress must not be negative", cfg.MaxIterWithoutProgress},
+		{cfg.Workers >= 0, "Workers must not be negative", cfg.Workers},
+		{cfg.Init >= InitRandom && cfg.Init <= InitCustom, "Init is not a valid initialization", cfg.Init},
+		{cfg.Init != InitCustom || cfg.InitialY != nil, "InitialY must be provided for InitCustom", cfg.InitialY},
+	}
+	for _, c := range checks {
+		if !c.ok {
+			return fmt.Errorf("%w: %s, got %v", ErrConfig, c.what, c.val)
+		}
+	}
+	return nil
+}
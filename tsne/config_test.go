@@ -0,0 +1,43 @@
+// Copyright (c) 2018 Daniel Augusto Rizzi Salvadori. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found in the LICENSE file.
+
+package tsne
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestNew verifies that New validates the configuration and that it can be read back.
+func TestNew(t *testing.T) {
+
+	cfg := DefaultConfig()
+	cfg.Theta = 0.5
+	tsne, err := New(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if tsne.Config != cfg || tsne.Theta != 0.5 {
+		t.Error("configuration was not stored")
+	}
+	cfg.Theta = -1
+	if _, err := New(cfg); !errors.Is(err, ErrConfig) {
+		t.Errorf("got error %v, expected %v", err, ErrConfig)
+	}
+	cfg = DefaultConfig()
+	cfg.DimsOut = 0
+	if _, err := New(cfg); !errors.Is(err, ErrDimsOut) {
+		t.Errorf("got error %v, expected %v", err, ErrDimsOut)
+	}
+	legacy := NewTSNE(3, 50, 100, 300, true)
+	if legacy.DimsOut != 3 || legacy.Perplexity != 50 || legacy.LearningRate != 100 || legacy.MaxIter != 300 || !legacy.Verbose {
+		t.Error("NewTSNE parameters were not stored")
+	}
+	for _, c := range []struct{ maxIter, phase int }{{100, 25}, {300, 75}, {1000, 250}, {5000, 250}} {
+		legacy := NewTSNE(2, 5, 100, c.maxIter, false)
+		if legacy.ExaggerationIters != c.phase || legacy.MomentumSwitchIter != c.phase {
+			t.Errorf("NewTSNE with %d iterations exaggerates for %d and switches momentum at %d, expected %d",
+				c.maxIter, legacy.ExaggerationIters, legacy.MomentumSwitchIter, c.phase)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright (c) 2018 Daniel Augusto Rizzi Salvadori. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found in the LICENSE file.
+
+package tsne
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Errors returned when validating the input and parameters of an embedding.
+// Returned errors wrap one of these, so they can be identified with errors.Is.
+var (
+	ErrEmptyInput       = errors.New("tsne: input has no datapoints")
+	ErrTooFewPoints     = errors.New("tsne: input has less than two datapoints")
+	ErrNotSquare        = errors.New("tsne: distance or affinity matrix is not square")
+	ErrNonFinite        = errors.New("tsne: input contains NaN or infinite values")
+	ErrNegativeDistance = errors.New("tsne: distance matrix contains negative values")
+	ErrNegativeAffinity = errors.New("tsne: affinity matrix contains negative values")
+	ErrZeroAffinities   = errors.New("tsne: affinity matrix has no positive values off its diagonal")
+	ErrPerplexity       = errors.New("tsne: perplexity must be positive and less than the number of datapoints")
+	ErrDimsOut          = errors.New("tsne: number of output dimensions must be at least 1")
+	ErrMaxIter          = errors.New("tsne: max number of iterations must not be negative")
+	ErrConfig           = errors.New("tsne: invalid configuration")
+	ErrNotEmbedded      = errors.New("tsne: there is no embedding to transform into")
+	ErrShape            = errors.New("tsne: input dimensions do not match the embedded data")
+	ErrCheckpoint       = errors.New("tsne: invalid checkpoint")
+)
+
+// ElementError reports an invalid element of an input matrix.
+// Err is ErrNonFinite, ErrNegativeDistance or ErrNegativeAffinity.
+type ElementError struct {
+	Row, Col int
+	Value    float64
+	Err      error
+}
+
+// Error implements the error interface.
+func (e *ElementError) Error() string {
+
+	return fmt.Sprintf("%v: element (%d, %d) is %v", e.Err, e.Row, e.Col, e.Value)
+}
+
+// Unwrap returns the underlying error.
+func (e *ElementError) Unwrap() error {
+
+	return e.Err
+}
+
+// validateParams verifies that the t-SNE parameters are valid for embedding n datapoints.
+func (tsne *TSNE) validateParams(n int) error {
+
+	if err := validateCount(n); err != nil {
+		return err
+	}
+	if err := tsne.Config.Validate(); err != nil {
+		return err
+	}
+	if tsne.Perplexity >= float64(n) {
+		return fmt.Errorf("%w: got %v for %d datapoints", ErrPerplexity, tsne.Perplexity, n)
+	}
+	return tsne.validateInit(n)
+}
+
+// validateParamsWithoutData verifies that the t-SNE parameters are valid for embedding n datapoints given by
+// precomputed affinities or neighbors. Since there is no data, InitPCA is not supported.
+func (tsne *TSNE) validateParamsWithoutData(n int) error {
+
+	if err := validateCount(n); err != nil {
+		return err
+	}
+	if err := tsne.Config.Validate(); err != nil {
+		return err
+	}
+	if tsne.Init == InitPCA {
+		return fmt.Errorf("%w: InitPCA requires data or distances", ErrConfig)
+	}
+	return tsne.validateInit(n)
+}
+
+// validateCount verifies that there are at least two datapoints, since a single one has no neighbors
+// to compute affinities with.
+func validateCount(n int) error {
+
+	if n == 0 {
+		return ErrEmptyInput
+	}
+	if n < 2 {
+		return fmt.Errorf("%w: got %d", ErrTooFewPoints, n)
+	}
+	return nil
+}
+
+// validateInit verifies that the initial embedding provided for InitCustom is valid for embedding n datapoints.
+func (tsne *TSNE) validateInit(n int) error {
+
+	if tsne.Init == InitCustom {
+		if r, c := tsne.InitialY.Dims(); r != n || c != tsne.DimsOut {
+			return fmt.Errorf("%w: InitialY must be %d by %d, got %d by %d", ErrConfig, n, tsne.DimsOut, r, c)
+		}
+		if err := validateData(tsne.InitialY); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateData verifies that the data matrix X only contains finite values.
+func validateData(X mat.Matrix) error {
+
+	n, d := X.Dims()
+	for i := 0; i < n; i++ {
+		for j := 0; j < d; j++ {
+			if v := X.At(i, j); math.IsNaN(v) || math.IsInf(v, 0) {
+				return &ElementError{Row: i, Col: j, Value: v, Err: ErrNonFinite}
+			}
+		}
+	}
+	return nil
+}
+
+// validateDistances verifies that the distance matrix D is square and only contains finite non-negative values.
+func validateDistances(D mat.Matrix) error {
+
+	if n, d := D.Dims(); n != d {
+		return fmt.Errorf("%w: got %d by %d", ErrNotSquare, n, d)
+	}
+	return validateNonNegative(D)
+}
+
+// validateNonNegative verifies that the (possibly rectangular) distance matrix D only contains finite non-negative values.
+func validateNonNegative(D mat.Matrix) error {
+
+	if err := validateData(D); err != nil {
+		return err
+	}
+	n, d := D.Dims()
+	for i := 0; i < n; i++ {
+		for j := 0; j < d; j++ {
+			if v := D.At(i, j); v < 0 {
+				return &ElementError{Row: i, Col: j, Value: v, Err: ErrNegativeDistance}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2018 Daniel Augusto Rizzi Salvadori. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found in the LICENSE file.
+
+package tsne
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// TestTryEmbedDistancesErrors verifies that invalid inputs and parameters are reported with the appropriate errors.
+func TestTryEmbedDistancesErrors(t *testing.T) {
+
+	valid := SquaredDistanceMatrix(randomData(10, 3))
+	withElement := func(v float64) mat.Matrix {
+		D := mat.DenseCopyOf(valid)
+		D.Set(2, 3, v)
+		return D
+	}
+	cases := []struct {
+		tsne *TSNE
+		D    mat.Matrix
+		err  error
+	}{
+		{NewTSNE(2, 3, 100, 10, false), mat.NewDense(3, 2, nil), ErrNotSquare},
+		{NewTSNE(2, 0.5, 100, 10, false), mat.NewDense(1, 1, nil), ErrTooFewPoints},
+		{NewTSNE(2, 3, 100, 10, false), withElement(math.NaN()), ErrNonFinite},
+		{NewTSNE(2, 3, 100, 10, false), withElement(math.Inf(1)), ErrNonFinite},
+		{NewTSNE(2, 3, 100, 10, false), withElement(-1), ErrNegativeDistance},
+		{NewTSNE(2, 10, 100, 10, false), valid, ErrPerplexity},
+		{NewTSNE(0, 3, 100, 10, false), valid, ErrDimsOut},
+		{NewTSNE(2, 3, 100, -1, false), valid, ErrMaxIter},
+	}
+	for i, c := range cases {
+		if _, err := c.tsne.TryEmbedDistances(c.D, nil); !errors.Is(err, c.err) {
+			t.Errorf("case %d: got error %v, expected %v", i, err, c.err)
+		}
+	}
+	var elemErr *ElementError
+	if _, err := NewTSNE(2, 3, 100, 10, false).TryEmbedDistances(withElement(-1), nil); !errors.As(err, &elemErr) || elemErr.Row != 2 || elemErr.Col != 3 {
+		t.Errorf("expected an element error at (2, 3), got %v", err)
+	}
+	if Y, err := NewTSNE(2, 3, 100, 10, false).TryEmbedDistances(valid, nil); err != nil || Y == nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+// TestTooFewPoints verifies that a single datapoint is rejected by every embedding method, instead of producing NaNs.
+func TestTooFewPoints(t *testing.T) {
+
+	for _, sparse := range []bool{false, true} {
+		tsne := NewTSNE(2, 0.5, 100, 10, false)
+		tsne.Sparse = sparse
+		if Y, err := tsne.TryEmbedData(mat.NewDense(1, 3, []float64{1, 2, 3}), nil); !errors.Is(err, ErrTooFewPoints) || Y != nil {
+			t.Errorf("sparse=%v: got error %v, expected %v", sparse, err, ErrTooFewPoints)
+		}
+	}
+	if _, err := NewTSNE(2, 0.5, 100, 10, false).EmbedAffinities(mat.NewDense(1, 1, []float64{1}), nil); !errors.Is(err, ErrTooFewPoints) {
+		t.Errorf("got error %v for affinities, expected %v", err, ErrTooFewPoints)
+	}
+	if _, err := NewTSNE(2, 0.5, 100, 10, false).EmbedNeighbors([][]int{{0}}, [][]float64{{0}}, nil); !errors.Is(err, ErrTooFewPoints) {
+		t.Errorf("got error %v for neighbors, expected %v", err, ErrTooFewPoints)
+	}
+}
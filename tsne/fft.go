@@ -0,0 +1,382 @@
+// Copyright (c) 2018 Daniel Augusto Rizzi Salvadori. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found in the LICENSE file.
+
+package tsne
+
+import (
+	"context"
+	"math"
+
+	"gonum.org/v1/gonum/dsp/fourier"
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+// fftRepulsiveForces computes the unnormalized repulsive forces on every point of Y into neg using
+// FFT-accelerated interpolation (as in FIt-SNE), for one- and two-dimensional embeddings. It returns the normalization term of Q.
+//
+// The repulsive forces are expressed in terms of the potentials phi_c(i) = sum_j K(yi, yj) c(yj) of the kernel
+// K = q f (see tKernel.at; the squared Student-t kernel for one degree of freedom) for the charges c(y) = 1 and
+// the coordinates of y. For one degree of freedom, the normalization term also follows from the potential of K
+// for the charge |y|², while otherwise it is the potential of q itself for the charge 1. The potentials are
+// interpolated from an equispaced grid covering the embedding, where they are computed as a convolution with the kernel using the FFT.
+func (tsne *TSNE) fftRepulsiveForces(Y *mat.Dense, neg []float64) float64 {
+
+	n, d := Y.Dims()
+	workers := tsne.workers()
+	tk := tsne.outputKernel()
+	grid := newInterpolationGrid(Y, tsne.FFTInterpolationPoints, tsne.FFTMinIntervals, tsne.FFTIntervalSize, workers)
+	kernel := grid.newKernel(func(dist2 float64) float64 {
+		q, f := tk.at(dist2)
+		return q * f
+	})
+	var sumKernel *gridKernel // Kernel of the normalization term, if it is not derived from kernel
+	if !tk.isCauchy() {
+		sumKernel = grid.newKernel(func(dist2 float64) float64 {
+			q, _ := tk.at(dist2)
+			return q
+		})
+	}
+	p := grid.nodes
+	charges := d + 2
+	// Compute the interpolation weights of every point over the nodes of its box
+	boxes := make([]int, n*d)
+	weights := make([]float64, n*d*p)
+	for i := 0; i < n; i++ {
+		grid.weights(Y.RawRowView(i), boxes[i*d:(i+1)*d], weights[i*d*p:(i+1)*d*p])
+	}
+	// Spread the charges of the points onto the grid
+	values := make([][]float64, charges)
+	for c := range values {
+		values[c] = make([]float64, grid.size())
+	}
+	charge := make([]float64, charges)
+	for i := 0; i < n; i++ {
+		yi := Y.RawRowView(i)
+		charge[0] = 1
+		copy(charge[1:], yi)
+		charge[d+1] = 1
+		if sumKernel == nil {
+			charge[d+1] = floats.Dot(yi, yi)
+		}
+		grid.forEachNode(boxes[i*d:(i+1)*d], weights[i*d*p:(i+1)*d*p], func(node int, w float64) {
+			for c := range values {
+				values[c][node] += w * charge[c]
+			}
+		})
+	}
+	// Compute the potentials at the grid nodes
+	last := charges // Charges whose potentials are computed with kernel
+	if sumKernel != nil {
+		last = d + 1
+		grid.convolve(sumKernel, values[d+1], nil)
+	}
+	for c := 0; c < last; c += 2 {
+		if c+1 < last {
+			grid.convolve(kernel, values[c], values[c+1])
+		} else {
+			grid.convolve(kernel, values[c], nil)
+		}
+	}
+	// Interpolate the potentials at every point and combine them into the forces
+	rowSumQ := make([]float64, n)
+	phi := make([][]float64, workers)
+	for w := range phi {
+		phi[w] = make([]float64, charges)
+	}
+	parallelFor(context.Background(), n, len(phi), func(w, i int) {
+		phi := phi[w]
+		for c := range phi {
+			phi[c] = 0
+		}
+		grid.forEachNode(boxes[i*d:(i+1)*d], weights[i*d*p:(i+1)*d*p], func(node int, w float64) {
+			for c := range phi {
+				phi[c] += w * values[c][node]
+			}
+		})
+		// The row sum of Q excludes the (interpolated) contribution of the point itself.
+		// For one degree of freedom, since K (1 + |yi-yj|²) is the Student-t kernel, it follows from the potentials of K
+		yi := Y.RawRowView(i)
+		negi := neg[i*d : (i+1)*d]
+		box, weight := boxes[i*d:(i+1)*d], weights[i*d*p:(i+1)*d*p]
+		if sumKernel != nil {
+			rowSumQ[i] = phi[d+1] - grid.selfPotential(sumKernel, box, weight)
+		} else {
+			rowSumQ[i] = (1+floats.Dot(yi, yi))*phi[0] + phi[d+1] - grid.selfPotential(kernel, box, weight)
+			for k := 0; k < d; k++ {
+				rowSumQ[i] -= 2 * yi[k] * phi[1+k]
+			}
+		}
+		for k := 0; k < d; k++ {
+			negi[k] = yi[k]*phi[0] - phi[1+k]
+		}
+	})
+	return floats.Sum(rowSumQ)
+}
+
+// interpolationGrid is an equispaced grid covering an embedding in one or two dimensions.
+// The square region containing the embedding is divided into intervals (boxes) along each dimension,
+// and each interval contains a fixed number of equispaced interpolation nodes.
+type interpolationGrid struct {
+	dims     int
+	nodes    int     // Number of interpolation nodes per interval
+	boxes    int     // Number of intervals per dimension
+	min      float64 // Lower bound of the region along every dimension
+	boxWidth float64 // Width of each interval
+	spacing  float64 // Distance between consecutive nodes
+	fft      *fft2   // Transform of the padded grid
+}
+
+// gridKernel is a kernel whose potentials are computed over an interpolationGrid.
+type gridKernel struct {
+	f        func(dist2 float64) float64 // Kernel as a function of the squared distance
+	spectrum []complex128                // Fourier coefficients of the kernel over the padded grid
+}
+
+// newInterpolationGrid returns a grid covering Y with the specified number of nodes per interval,
+// and intervals of at most intervalSize, using at least minIntervals intervals per dimension.
+// The FFTs are computed using the specified number of workers.
+func newInterpolationGrid(Y *mat.Dense, nodes, minIntervals int, intervalSize float64, workers int) *interpolationGrid {
+
+	data := Y.RawMatrix().Data
+	min, max := floats.Min(data), floats.Max(data)
+	span := max - min
+	if span == 0 {
+		span = 1
+	}
+	boxes := int(math.Ceil(span / intervalSize))
+	if boxes < minIntervals {
+		boxes = minIntervals
+	}
+	_, dims := Y.Dims()
+	boxWidth := span / float64(boxes)
+	g := &interpolationGrid{
+		dims:     dims,
+		nodes:    nodes,
+		boxes:    boxes,
+		min:      min,
+		boxWidth: boxWidth,
+		spacing:  boxWidth / float64(nodes),
+	}
+	rows, cols := g.padded()
+	g.fft = newFFT2(rows, cols, workers)
+	return g
+}
+
+// newKernel returns the kernel f (as a function of the squared distance) over the grid, precomputing its spectrum.
+func (g *interpolationGrid) newKernel(f func(dist2 float64) float64) *gridKernel {
+
+	// Compute the spectrum of the kernel, as a function of the (circular) offset between nodes of the padded grid
+	k := &gridKernel{f: f}
+	rows, cols := g.padded()
+	offset := func(i, n int) int {
+		if i > n/2 {
+			return i - n
+		}
+		return i
+	}
+	k.spectrum = make([]complex128, rows*cols)
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			k.spectrum[i*cols+j] = complex(g.kernel(k, offset(i, rows), offset(j, cols)), 0)
+		}
+	}
+	g.fft.transform(k.spectrum, false, rows)
+	return k
+}
+
+// padded returns the dimensions of the padded grid used to compute convolutions,
+// which has twice as many nodes as the grid along each dimension.
+func (g *interpolationGrid) padded() (rows, cols int) {
+
+	if g.dims == 1 {
+		return 2 * g.side(), 1
+	}
+	return 2 * g.side(), 2 * g.side()
+}
+
+// side returns the number of nodes along each dimension.
+func (g *interpolationGrid) side() int {
+
+	return g.boxes * g.nodes
+}
+
+// size returns the total number of nodes.
+func (g *interpolationGrid) size() int {
+
+	if g.dims == 1 {
+		return g.side()
+	}
+	return g.side() * g.side()
+}
+
+// weights finds the box containing y along each dimension and computes the Lagrange interpolation weights
+// of y over the nodes of that box. The weights of the k-th dimension are stored in w[k*nodes:(k+1)*nodes].
+func (g *interpolationGrid) weights(y []float64, box []int, w []float64) {
+
+	for k, v := range y {
+		b := int((v - g.min) / g.boxWidth)
+		if b >= g.boxes {
+			b = g.boxes - 1
+		}
+		box[k] = b
+		// Position of y relative to the box, in units of the node spacing (the nodes are at 0.5, 1.5, ...)
+		t := (v-g.min)/g.spacing - float64(b*g.nodes)
+		wk := w[k*g.nodes : (k+1)*g.nodes]
+		for a := range wk {
+			wk[a] = 1
+			for m := 0; m < g.nodes; m++ {
+				if m != a {
+					wk[a] *= (t - float64(m) - 0.5) / float64(a-m)
+				}
+			}
+		}
+	}
+}
+
+// forEachNode calls f with the index and interpolation weight of each node of the specified boxes.
+func (g *interpolationGrid) forEachNode(box []int, w []float64, f func(node int, w float64)) {
+
+	p := g.nodes
+	if g.dims == 1 {
+		for a := 0; a < p; a++ {
+			f(box[0]*p+a, w[a])
+		}
+		return
+	}
+	side := g.side()
+	for a := 0; a < p; a++ {
+		row := (box[0]*p + a) * side
+		for b := 0; b < p; b++ {
+			f(row+box[1]*p+b, w[a]*w[p+b])
+		}
+	}
+}
+
+// selfPotential returns the interpolated kernel k between a point and itself, given its boxes and interpolation weights.
+// It differs from the exact value (one) by the interpolation error.
+func (g *interpolationGrid) selfPotential(k *gridKernel, box []int, w []float64) float64 {
+
+	var self float64
+	g.forEachNode(box, w, func(a int, wa float64) {
+		g.forEachNode(box, w, func(b int, wb float64) {
+			self += wa * wb * g.kernelAt(k, a, b)
+		})
+	})
+	return self
+}
+
+// kernelAt returns the kernel k between the nodes with indices a and b.
+func (g *interpolationGrid) kernelAt(k *gridKernel, a, b int) float64 {
+
+	if g.dims == 1 {
+		return g.kernel(k, a-b, 0)
+	}
+	side := g.side()
+	return g.kernel(k, a/side-b/side, a%side-b%side)
+}
+
+// kernel returns the kernel k between two nodes whose indices differ by di and dj along each dimension.
+func (g *interpolationGrid) kernel(k *gridKernel, di, dj int) float64 {
+
+	return k.f(g.spacing * g.spacing * float64(di*di+dj*dj))
+}
+
+// convolve replaces the values at the nodes by their convolution with the kernel k, i.e. the potentials at the nodes.
+// The convolution is computed with the FFT by embedding it in a circular convolution over the padded grid.
+// Since the kernel is real, two sets of values (a and b, which may be nil) are convolved at once
+// as the real and imaginary parts of a single complex signal.
+func (g *interpolationGrid) convolve(k *gridKernel, a, b []float64) {
+
+	side := g.side()
+	rows, cols := g.padded()
+	stride := side // Distance between consecutive rows of values
+	if g.dims == 1 {
+		stride = 1
+	}
+	signal := make([]complex128, rows*cols)
+	for i := 0; i < side; i++ {
+		for j := 0; j < cols && j < side; j++ {
+			var v complex128
+			if b != nil {
+				v = complex(0, b[i*stride+j])
+			}
+			signal[i*cols+j] = complex(a[i*stride+j], 0) + v
+		}
+	}
+	// Multiply the spectra of the kernel and the values
+	g.fft.transform(signal, false, side)
+	for e := range signal {
+		signal[e] *= k.spectrum[e]
+	}
+	g.fft.transform(signal, true, side)
+	scale := 1 / float64(rows*cols)
+	for i := 0; i < side; i++ {
+		for j := 0; j < cols && j < side; j++ {
+			a[i*stride+j] = real(signal[i*cols+j]) * scale
+			if b != nil {
+				b[i*stride+j] = imag(signal[i*cols+j]) * scale
+			}
+		}
+	}
+}
+
+// fft2 computes two-dimensional (unnormalized) discrete Fourier transforms of row-major rows by cols arrays,
+// transforming the rows and the columns in parallel.
+type fft2 struct {
+	rows, cols int
+	rowFFT     []*fourier.CmplxFFT // Transform of the rows used by each worker
+	colFFT     []*fourier.CmplxFFT // Transform of the columns used by each worker
+	colBuf     [][]complex128      // Buffer for a column used by each worker
+}
+
+// newFFT2 returns an fft2 for rows by cols arrays, using the specified number of workers.
+func newFFT2(rows, cols, workers int) *fft2 {
+
+	f := &fft2{rows: rows, cols: cols}
+	for w := 0; w < workers; w++ {
+		f.rowFFT = append(f.rowFFT, fourier.NewCmplxFFT(cols))
+		f.colFFT = append(f.colFFT, fourier.NewCmplxFFT(rows))
+		f.colBuf = append(f.colBuf, make([]complex128, rows))
+	}
+	return f
+}
+
+// transform computes the Fourier coefficients of x in place, or the sequence if inverse is true.
+// Only the first active rows of x are assumed to be non-zero when computing the coefficients,
+// and only the first active rows of the sequence are computed, skipping the transforms of the other rows.
+func (f *fft2) transform(x []complex128, inverse bool, active int) {
+
+	apply := func(t *fourier.CmplxFFT, v []complex128) {
+		if inverse {
+			t.Sequence(v, v)
+		} else {
+			t.Coefficients(v, v)
+		}
+	}
+	ctx := context.Background()
+	workers := len(f.rowFFT)
+	transformRows := func() {
+		if f.cols > 1 {
+			parallelFor(ctx, active, workers, func(w, i int) {
+				apply(f.rowFFT[w], x[i*f.cols:(i+1)*f.cols])
+			})
+		}
+	}
+	if !inverse {
+		transformRows()
+	}
+	parallelFor(ctx, f.cols, workers, func(w, j int) {
+		col := f.colBuf[w]
+		for i := range col {
+			col[i] = x[i*f.cols+j]
+		}
+		apply(f.colFFT[w], col)
+		for i, v := range col {
+			x[i*f.cols+j] = v
+		}
+	})
+	if inverse {
+		transformRows()
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2018 Daniel Augusto Rizzi Salvadori. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found in the LICENSE file.
+
+package tsne
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+// TestFFTRepulsiveForces verifies that the repulsive forces interpolated with the FFT approximate the exact ones
+// for several degrees of freedom of the kernel, and that the approximation improves as the grid is refined.
+func TestFFTRepulsiveForces(t *testing.T) {
+
+	for _, alpha := range []float64{1, 0.5} {
+		for _, dims := range []int{1, 2} {
+			Y := randomData(300, dims)
+			Y.Scale(10, Y)
+			n, d := Y.Dims()
+			exact := make([]float64, n*d)
+			exactSumQ := exactRepulsiveForces(Y, tKernel{alpha, alpha}, exact, 1)
+			prevErr := math.Inf(1)
+			for _, nodes := range []int{2, 3, 5} {
+				cfg := DefaultConfig()
+				cfg.DegreesOfFreedom = alpha
+				cfg.FFTInterpolationPoints = nodes
+				cfg.FFTIntervalSize = 0.5
+				tsne, _ := New(cfg)
+				approx := make([]float64, n*d)
+				sumQ := tsne.fftRepulsiveForces(Y, approx)
+				floats.Sub(approx, exact)
+				relErr := floats.Norm(approx, 2) / floats.Norm(exact, 2)
+				if relErr >= prevErr {
+					t.Errorf("alpha=%v: %d-D repulsive forces with %d nodes have relative error %v, no better than with fewer nodes", alpha, dims, nodes, relErr)
+				}
+				prevErr = relErr
+				if nodes == 5 && (relErr > 1e-3 || math.Abs(sumQ-exactSumQ) > 1e-3*exactSumQ) {
+					t.Errorf("alpha=%v: %d-D repulsive forces have relative error %v, normalization term is %v, expected %v", alpha, dims, relErr, sumQ, exactSumQ)
+				}
+			}
+		}
+	}
+}
+
+// TestFFTGradient verifies that the gradient computed with MethodFFT approximates the exact one on an optimized embedding,
+// and that MethodFFT is rejected for more than two dimensions.
+func TestFFTGradient(t *testing.T) {
+
+	cfg := DefaultConfig()
+	cfg.Perplexity = 10
+	cfg.Seed = 1
+	tsne, _ := New(cfg)
+	if _, err := tsne.TryEmbedData(randomData(200, 5), nil); err != nil {
+		t.Fatal(err)
+	}
+	exactDiv := tsne.gradient(1)
+	exact := mat.DenseCopyOf(tsne.dCdY)
+	tsne.Method = MethodFFT
+	div := tsne.gradient(1)
+	// Since the attractive and repulsive forces almost cancel out, the error is relative to the repulsive forces
+	neg := make([]float64, 400)
+	sumQ := exactRepulsiveForces(tsne.Y, tsne.outputKernel(), neg, 1)
+	var diff mat.Dense
+	diff.Sub(tsne.dCdY, exact)
+	if relErr := mat.Norm(&diff, 2) / (4 * floats.Norm(neg, 2) / sumQ); relErr > 0.02 || math.Abs(div-exactDiv) > 1e-3*exactDiv {
+		t.Errorf("gradient with MethodFFT has relative error %v, divergence is %v, expected %v", relErr, div, exactDiv)
+	}
+	cfg.Method = MethodFFT
+	cfg.DimsOut = 3
+	if _, err := New(cfg); !errors.Is(err, ErrConfig) {
+		t.Errorf("expected ErrConfig for a three-dimensional embedding, got %v", err)
+	}
+}
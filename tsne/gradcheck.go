@@ -0,0 +1,70 @@
+// Copyright (c) 2018 Daniel Augusto Rizzi Salvadori. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found in the LICENSE file.
+
+package tsne
+
+import (
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// GradientCheck is the result of comparing the analytic gradient of the KL divergence
+// against its central finite differences approximation.
+type GradientCheck struct {
+	Analytic    *mat.Dense // Gradient computed by the t-SNE gradient engine
+	Numerical   *mat.Dense // Gradient approximated by central finite differences
+	MaxAbsError float64    // Largest absolute difference between the two gradients
+	RelError    float64    // Relative error ‖Analytic − Numerical‖ / (‖Analytic‖ + ‖Numerical‖)
+}
+
+// CheckGradient compares the analytic gradient of the KL divergence between P and the low dimensional
+// affinities of Y against central finite differences of the divergence with step h.
+// P must be a symmetric joint probability matrix (summing to one), either dense or a *SparseMatrix.
+// The gradient is computed with the same engine used by the optimization (as configured in tsne),
+// so it can be used to validate new gradient computations. The state of tsne is not modified.
+// Approximations such as Barnes-Hut make the divergence itself approximate, so the
+// comparison is only meaningful for the exact computation (Theta = 0).
+func (tsne *TSNE) CheckGradient(P, Y mat.Matrix, h float64) GradientCheck {
+
+	// Set up a scratch copy of the t-SNE object for the given P and Y
+	check := *tsne
+	check.n, check.DimsOut = Y.Dims()
+	if sparse, ok := P.(*SparseMatrix); ok {
+		check.P, check.PSparse = nil, sparse
+	} else {
+		check.P, check.PSparse = mat.DenseCopyOf(P), nil
+	}
+	check.Y = mat.DenseCopyOf(Y)
+	check.dCdY = mat.NewDense(check.n, check.DimsOut, nil)
+	check.computePlogP()
+	// Compute the analytic gradient
+	check.gradient(1)
+	result := GradientCheck{
+		Analytic:  mat.DenseCopyOf(check.dCdY),
+		Numerical: mat.NewDense(check.n, check.DimsOut, nil),
+	}
+	// Approximate each partial derivative by central finite differences
+	for i := 0; i < check.n; i++ {
+		for k := 0; k < check.DimsOut; k++ {
+			orig := check.Y.At(i, k)
+			check.Y.Set(i, k, orig+h)
+			costPlus := check.gradient(1)
+			check.Y.Set(i, k, orig-h)
+			costMinus := check.gradient(1)
+			check.Y.Set(i, k, orig)
+			result.Numerical.Set(i, k, (costPlus-costMinus)/(2*h))
+		}
+	}
+	// Compare the gradients
+	diff := mat.NewDense(check.n, check.DimsOut, nil)
+	diff.Sub(result.Analytic, result.Numerical)
+	for _, v := range diff.RawMatrix().Data {
+		result.MaxAbsError = math.Max(result.MaxAbsError, math.Abs(v))
+	}
+	normSum := mat.Norm(result.Analytic, 2) + mat.Norm(result.Numerical, 2)
+	if normSum > 0 {
+		result.RelError = mat.Norm(diff, 2) / normSum
+	}
+	return result
+}
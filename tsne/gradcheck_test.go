@@ -0,0 +1,88 @@
+// Copyright (c) 2018 Daniel Augusto Rizzi Salvadori. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found in the LICENSE file.
+
+package tsne
+
+import (
+	"errors"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// TestCheckGradient verifies the analytic gradient of every exact gradient computation against finite differences,
+// for several degrees of freedom of the kernel, including the kernel of parametric t-SNE in three dimensions.
+func TestCheckGradient(t *testing.T) {
+
+	X := randomData(30, 4)
+	for _, kernel := range []struct {
+		dof  float64
+		dims int
+	}{{1, 2}, {0.5, 2}, {2, 2}, {AutoDegreesOfFreedom, 3}} {
+		for _, sparse := range []bool{false, true} {
+			for _, split := range []bool{false, true} {
+				tsne := NewTSNE(kernel.dims, 5, 100, 1, false)
+				tsne.Sparse = sparse
+				tsne.DegreesOfFreedom = kernel.dof
+				tsne.EmbedData(X, nil)
+				var P mat.Matrix = tsne.P
+				if sparse {
+					P = tsne.PSparse
+				} else if split {
+					// Force the split computation with a dense P (Barnes-Hut with no summarized cells)
+					tsne.Theta = 1e-12
+				}
+				check := tsne.CheckGradient(P, randomData(30, kernel.dims), 1e-5)
+				if check.RelError > 1e-6 {
+					t.Errorf("dof=%v sparse=%v split=%v: relative gradient error %v", kernel.dof, sparse, split, check.RelError)
+				}
+			}
+		}
+	}
+}
+
+// TestDegreesOfFreedom verifies the resolution of the degrees of freedom into the scale and exponent of the kernel.
+func TestDegreesOfFreedom(t *testing.T) {
+
+	for _, c := range []struct {
+		dof      float64
+		dimsOut  int
+		expected tKernel
+	}{
+		{0, 2, tKernel{1, 1}},
+		{0.5, 2, tKernel{0.5, 0.5}},
+		{AutoDegreesOfFreedom, 1, tKernel{1, 1}},
+		{AutoDegreesOfFreedom, 2, tKernel{1, 1}},
+		{AutoDegreesOfFreedom, 3, tKernel{2, 1.5}},
+		{AutoDegreesOfFreedom, 5, tKernel{4, 2.5}},
+	} {
+		cfg := DefaultConfig()
+		cfg.DegreesOfFreedom = c.dof
+		cfg.DimsOut = c.dimsOut
+		tsne, err := New(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := tsne.outputKernel(); got != c.expected {
+			t.Errorf("DegreesOfFreedom %v in %d dimensions resolved to %v, expected %v", c.dof, c.dimsOut, got, c.expected)
+		}
+	}
+	cfg := DefaultConfig()
+	cfg.DegreesOfFreedom = -2
+	if _, err := New(cfg); !errors.Is(err, ErrConfig) {
+		t.Errorf("expected ErrConfig for negative degrees of freedom, got %v", err)
+	}
+}
+
+// TestGradientIsNotAccumulated verifies that consecutive gradient computations are independent.
+func TestGradientIsNotAccumulated(t *testing.T) {
+
+	tsne := NewTSNE(2, 5, 100, 1, false)
+	tsne.EmbedData(randomData(20, 3), nil)
+	tsne.costGradient(tsne.P, tsne.Y, 1)
+	first := mat.DenseCopyOf(tsne.dCdY)
+	tsne.costGradient(tsne.P, tsne.Y, 1)
+	if !mat.Equal(first, tsne.dCdY) {
+		t.Error("gradient accumulated across iterations")
+	}
+}
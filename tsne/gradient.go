@@ -0,0 +1,221 @@
+// Copyright (c) 2018 Daniel Augusto Rizzi Salvadori. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found in the LICENSE file.
+
+package tsne
+
+import (
+	"context"
+	"math"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+// GradientMethod selects how the gradient of the divergence is computed.
+type GradientMethod int
+
+const (
+	// MethodAuto uses Barnes-Hut if Theta is positive, and the exact gradient otherwise.
+	MethodAuto GradientMethod = iota
+	// MethodExact computes the exact O(n²) gradient.
+	MethodExact
+	// MethodBarnesHut approximates the repulsive forces with a Barnes-Hut tree in O(n log n), with accuracy controlled by Theta.
+	// It is intended for two- and three-dimensional embeddings.
+	MethodBarnesHut
+	// MethodFFT approximates the repulsive forces by interpolating them from a grid where they are computed with the FFT
+	// (FIt-SNE), in O(n) for a given grid. It only supports one- and two-dimensional embeddings, and it is
+	// the fastest method for large datasets.
+	MethodFFT
+)
+
+var gradientMethodNames = []string{"auto", "exact", "Barnes-Hut", "FFT"}
+
+// String returns the name of the method.
+func (m GradientMethod) String() string {
+
+	if m < 0 || int(m) >= len(gradientMethodNames) {
+		return "unknown"
+	}
+	return gradientMethodNames[m]
+}
+
+// method returns the gradient method to use, resolving MethodAuto.
+func (tsne *TSNE) method() GradientMethod {
+
+	if tsne.Method != MethodAuto {
+		return tsne.Method
+	}
+	if tsne.Theta > 0 {
+		return MethodBarnesHut
+	}
+	return MethodExact
+}
+
+// outputKernel returns the low dimensional kernel for the configured degrees of freedom, resolving the zero value
+// and AutoDegreesOfFreedom.
+func (tsne *TSNE) outputKernel() tKernel {
+
+	switch tsne.DegreesOfFreedom {
+	case 0:
+		return tKernel{1, 1}
+	case AutoDegreesOfFreedom:
+		nu := math.Max(float64(tsne.DimsOut-1), 1)
+		return tKernel{nu, (nu + 1) / 2}
+	}
+	return tKernel{tsne.DegreesOfFreedom, tsne.DegreesOfFreedom}
+}
+
+// splitCostGradient computes the Kullback-Leibler divergence between P and Q and its gradient
+// with respect to Y as the difference between attractive forces (which only depend on the non-zero
+// entries of P) and repulsive forces (which are computed exactly, with Barnes-Hut, or with FFT-accelerated interpolation).
+// Unlike costGradient, it never allocates n by n matrices. The forces on each point are computed in parallel.
+// The attractive forces are multiplied by the specified exaggeration factor, while the divergence is not.
+func (tsne *TSNE) splitCostGradient(Y *mat.Dense, exaggeration float64) float64 {
+
+	n, d := Y.Dims()
+	workers := tsne.workers()
+	// Compute the repulsive forces and the normalization term of Q
+	neg := make([]float64, n*d)
+	var sumQ float64
+	switch tsne.method() {
+	case MethodBarnesHut:
+		sumQ = tsne.bhRepulsiveForces(Y, neg)
+	case MethodFFT:
+		sumQ = tsne.fftRepulsiveForces(Y, neg)
+	default:
+		sumQ = exactRepulsiveForces(Y, tsne.outputKernel(), neg, workers)
+	}
+	// Compute the attractive forces and the non-constant portion of the divergence
+	pos := make([]float64, n*d)
+	var PlogQ float64
+	if tsne.PSparse != nil {
+		PlogQ = sparseAttractiveForces(tsne.PSparse, Y, tsne.outputKernel(), sumQ, pos, workers)
+	} else {
+		PlogQ = denseAttractiveForces(tsne.P, Y, tsne.outputKernel(), sumQ, pos, workers)
+	}
+	// Combine the attractive and repulsive forces into the gradient
+	for i := 0; i < n; i++ {
+		for k := 0; k < d; k++ {
+			tsne.dCdY.Set(i, k, 4*(exaggeration*pos[i*d+k]-neg[i*d+k]/sumQ))
+		}
+	}
+	return tsne.PlogP - PlogQ
+}
+
+// exactRepulsiveForces computes the unnormalized repulsive forces on every point of Y into neg for the kernel tk
+// by visiting all pairs of points, using the specified number of goroutines.
+// It returns the normalization term of Q.
+func exactRepulsiveForces(Y *mat.Dense, tk tKernel, neg []float64, workers int) float64 {
+
+	n, d := Y.Dims()
+	rowSumQ := make([]float64, n)
+	parallelFor(context.Background(), n, workers, func(w, i int) {
+		yi := Y.RawRowView(i)
+		negi := neg[i*d : (i+1)*d]
+		for j := 0; j < n; j++ {
+			if i == j {
+				continue
+			}
+			yj := Y.RawRowView(j)
+			q, f := tk.at(sqDist(yi, yj))
+			rowSumQ[i] += q
+			for k := 0; k < d; k++ {
+				negi[k] += q * f * (yi[k] - yj[k])
+			}
+		}
+	})
+	return floats.Sum(rowSumQ)
+}
+
+// denseAttractiveForces computes the attractive forces on every point of Y into pos for a dense P and the kernel tk,
+// using the specified number of goroutines. It returns the sum of P .* log(Q), with Q normalized by sumQ.
+func denseAttractiveForces(P, Y *mat.Dense, tk tKernel, sumQ float64, pos []float64, workers int) float64 {
+
+	n, d := Y.Dims()
+	rowPlogQ := make([]float64, n)
+	parallelFor(context.Background(), n, workers, func(w, i int) {
+		yi := Y.RawRowView(i)
+		Pi := P.RawRowView(i)
+		posi := pos[i*d : (i+1)*d]
+		for j := 0; j < n; j++ {
+			if i == j {
+				continue
+			}
+			yj := Y.RawRowView(j)
+			q, f := tk.at(sqDist(yi, yj))
+			rowPlogQ[i] += Pi[j] * math.Log(math.Max(q/sumQ, GreaterThanZero))
+			mult := Pi[j] * f
+			for k := 0; k < d; k++ {
+				posi[k] += mult * (yi[k] - yj[k])
+			}
+		}
+	})
+	return floats.Sum(rowPlogQ)
+}
+
+// sparseAttractiveForces computes the attractive forces on every point of Y into pos for a sparse P and the kernel tk,
+// using the specified number of goroutines.
+// It returns the sum of P .* log(Q) over the non-zero entries of P, with Q normalized by sumQ.
+func sparseAttractiveForces(P *SparseMatrix, Y *mat.Dense, tk tKernel, sumQ float64, pos []float64, workers int) float64 {
+
+	n, d := Y.Dims()
+	rowPlogQ := make([]float64, n)
+	parallelFor(context.Background(), n, workers, func(w, i int) {
+		yi := Y.RawRowView(i)
+		posi := pos[i*d : (i+1)*d]
+		for e := P.RowPtr[i]; e < P.RowPtr[i+1]; e++ {
+			j := P.ColIdx[e]
+			if i == j {
+				continue
+			}
+			yj := Y.RawRowView(j)
+			q, f := tk.at(sqDist(yi, yj))
+			rowPlogQ[i] += P.Val[e] * math.Log(math.Max(q/sumQ, GreaterThanZero))
+			mult := P.Val[e] * f
+			for k := 0; k < d; k++ {
+				posi[k] += mult * (yi[k] - yj[k])
+			}
+		}
+	})
+	return floats.Sum(rowPlogQ)
+}
+
+// tKernel is the heavy-tailed low dimensional kernel (1 + dist2/scale)^-exponent. The kernel with α degrees of freedom
+// has scale and exponent α, while the Student-t kernel with ν degrees of freedom of parametric t-SNE has scale ν
+// and exponent (ν + 1)/2. Both are the Student-t kernel of classic t-SNE for one degree of freedom.
+type tKernel struct {
+	scale, exponent float64
+}
+
+// at returns the unnormalized low dimensional affinity q between two points at squared distance dist2, along with
+// f = -d log(q) / d dist2 = (exponent/scale) / (1 + dist2/scale), so that the gradient of the divergence is
+// 4 sum_j (p_ij - q_ij) f_ij (yi - yj).
+func (k tKernel) at(dist2 float64) (q, f float64) {
+
+	g := 1 / (1 + dist2/k.scale)
+	f = g
+	if k.exponent != k.scale {
+		f = g * k.exponent / k.scale
+	}
+	if k.exponent == 1 {
+		return g, f
+	}
+	return math.Pow(g, k.exponent), f
+}
+
+// isCauchy returns whether the kernel is the Student-t kernel with one degree of freedom, 1 / (1 + dist2).
+func (k tKernel) isCauchy() bool {
+
+	return k.scale == 1 && k.exponent == 1
+}
+
+// sqDist returns the squared euclidean distance between a and b.
+func sqDist(a, b []float64) float64 {
+
+	var dist2 float64
+	for k := range a {
+		diff := a[k] - b[k]
+		dist2 += diff * diff
+	}
+	return dist2
+}
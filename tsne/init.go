@@ -0,0 +1,148 @@
+// Copyright (c) 2018 Daniel Augusto Rizzi Salvadori. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found in the LICENSE file.
+
+package tsne
+
+import (
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat"
+)
+
+// Initialization selects how the embedding is initialized before the optimization.
+type Initialization int
+
+const (
+	// InitRandom draws the embedding from a Gaussian distribution with standard deviation InitialStandardDeviation.
+	InitRandom Initialization = iota
+	// InitPCA uses the first DimsOut principal components of the data, which preserves its global structure.
+	// When embedding distances, where no data is available, classical multidimensional scaling (MDS) of the
+	// distances is used instead (equivalent to PCA for euclidean distances). MDS requires an n by n
+	// eigendecomposition. The result is scaled so the first dimension has standard deviation InitialStandardDeviation.
+	// Dimensions beyond the rank of the data are filled with small random values.
+	// It is not supported when embedding precomputed affinities.
+	InitPCA
+	// InitCustom uses the initial embedding provided in InitialY.
+	InitCustom
+)
+
+// pcaFillScale is the standard deviation, relative to InitialStandardDeviation, of the random values used for the
+// dimensions of a PCA initialization beyond the rank of the data.
+const pcaFillScale = 1e-2
+
+// minEigenvalue is the smallest eigenvalue, relative to the largest one, of the components used by PCA and MDS.
+const minEigenvalue = 1e-12
+
+// initialEmbedding returns the initial embedding according to the Init configuration.
+// X is the data matrix and D the (squared) distance matrix; only one of them is required.
+func (tsne *TSNE) initialEmbedding(X, D mat.Matrix) *mat.Dense {
+
+	switch tsne.Init {
+	case InitPCA:
+		var Y *mat.Dense
+		var components int
+		if X != nil {
+			Y, components = pcaEmbedding(X, tsne.DimsOut)
+		} else {
+			Y, components = mdsEmbedding(D, tsne.DimsOut)
+		}
+		scaleEmbedding(Y, InitialStandardDeviation)
+		// The gradient along a constant dimension is zero, so the dimensions without a component would never move
+		for i := 0; i < tsne.n; i++ {
+			for j := components; j < tsne.DimsOut; j++ {
+				Y.Set(i, j, tsne.rng.NormFloat64()*InitialStandardDeviation*pcaFillScale)
+			}
+		}
+		return Y
+	case InitCustom:
+		return mat.DenseCopyOf(tsne.InitialY)
+	default:
+		Y := mat.NewDense(tsne.n, tsne.DimsOut, nil)
+		Y.Apply(func(i, j int, v float64) float64 {
+			return tsne.rng.NormFloat64() * InitialStandardDeviation
+		}, Y)
+		return Y
+	}
+}
+
+// pcaEmbedding projects the rows of X onto its first dims principal components, and returns the number of them
+// with positive variance. If X has lower rank than dims, the remaining dimensions are zero.
+func pcaEmbedding(X mat.Matrix, dims int) (*mat.Dense, int) {
+
+	n, d := X.Dims()
+	// Center the data
+	Xc := mat.DenseCopyOf(X)
+	for j := 0; j < d; j++ {
+		col := mat.Col(nil, j, Xc)
+		mean := stat.Mean(col, nil)
+		for i := 0; i < n; i++ {
+			Xc.Set(i, j, col[i]-mean)
+		}
+	}
+	// Compute the eigenvectors of the covariance matrix (in ascending order of eigenvalue)
+	cov := mat.NewSymDense(d, nil)
+	cov.SymOuterK(1, Xc.T())
+	var eig mat.EigenSym
+	eig.Factorize(cov, true)
+	values := eig.Values(nil)
+	var vecs mat.Dense
+	eig.VectorsTo(&vecs)
+	// Project the data onto the eigenvectors with the largest eigenvalues
+	Y := mat.NewDense(n, dims, nil)
+	proj := mat.NewVecDense(n, nil)
+	k := 0
+	for ; k < dims && k < d && values[d-1-k] > minEigenvalue*values[d-1]; k++ {
+		proj.MulVec(Xc, vecs.ColView(d-1-k))
+		Y.SetCol(k, proj.RawVector().Data)
+	}
+	return Y, k
+}
+
+// mdsEmbedding performs classical multidimensional scaling of the squared distance matrix D into dims dimensions,
+// and returns the number of dimensions with positive eigenvalues. The remaining dimensions are zero.
+func mdsEmbedding(D mat.Matrix, dims int) (*mat.Dense, int) {
+
+	n, _ := D.Dims()
+	// Double center the squared distances: B = -1/2 J D J with J = I - 11'/n
+	rowMean := make([]float64, n)
+	var mean float64
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			v := (D.At(i, j) + D.At(j, i)) / 2
+			rowMean[i] += v / float64(n)
+		}
+		mean += rowMean[i] / float64(n)
+	}
+	B := mat.NewSymDense(n, nil)
+	for i := 0; i < n; i++ {
+		for j := i; j < n; j++ {
+			v := (D.At(i, j) + D.At(j, i)) / 2
+			B.SetSym(i, j, -(v-rowMean[i]-rowMean[j]+mean)/2)
+		}
+	}
+	// The embedding is given by the eigenvectors with the largest eigenvalues, scaled by their square roots
+	var eig mat.EigenSym
+	eig.Factorize(B, true)
+	values := eig.Values(nil)
+	var vecs mat.Dense
+	eig.VectorsTo(&vecs)
+	Y := mat.NewDense(n, dims, nil)
+	k := 0
+	for ; k < dims && k < n && values[n-1-k] > minEigenvalue*values[n-1]; k++ {
+		scale := math.Sqrt(values[n-1-k])
+		for i := 0; i < n; i++ {
+			Y.Set(i, k, vecs.At(i, n-1-k)*scale)
+		}
+	}
+	return Y, k
+}
+
+// scaleEmbedding scales Y so that its first column has the specified standard deviation.
+func scaleEmbedding(Y *mat.Dense, std float64) {
+
+	current := stat.StdDev(mat.Col(nil, 0, Y), nil)
+	if current > 0 {
+		Y.Scale(std/current, Y)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2018 Daniel Augusto Rizzi Salvadori. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found in the LICENSE file.
+
+package tsne
+
+import (
+	"math"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat"
+)
+
+// TestPCAInitialization verifies that PCA of the data and MDS of its squared euclidean distances
+// yield the same initial embedding (up to the sign of each dimension), scaled as expected.
+func TestPCAInitialization(t *testing.T) {
+
+	X := randomData(25, 4)
+	tsne := NewTSNE(2, 5, 100, 0, false)
+	tsne.Init = InitPCA
+	tsne.n = 25
+	pca := tsne.initialEmbedding(X, nil)
+	mds := tsne.initialEmbedding(nil, SquaredDistanceMatrix(X))
+	if std := stat.StdDev(mat.Col(nil, 0, pca), nil); math.Abs(std-InitialStandardDeviation) > 1e-12 {
+		t.Errorf("first dimension has standard deviation %v, expected %v", std, InitialStandardDeviation)
+	}
+	for k := 0; k < 2; k++ {
+		sign := math.Copysign(1, pca.At(0, k)*mds.At(0, k))
+		for i := 0; i < 25; i++ {
+			if math.Abs(pca.At(i, k)-sign*mds.At(i, k)) > 1e-9 {
+				t.Fatalf("PCA and MDS initializations differ at (%d, %d)", i, k)
+			}
+		}
+	}
+}
+
+// TestCustomInitialization verifies that the provided initial embedding is used.
+func TestCustomInitialization(t *testing.T) {
+
+	X := randomData(20, 3)
+	tsne := NewTSNE(2, 5, 100, 0, false)
+	tsne.Init = InitCustom
+	tsne.InitialY = randomData(20, 2)
+	Y, err := tsne.TryEmbedData(X, nil)
+	if err != nil || !mat.Equal(Y, tsne.InitialY) {
+		t.Errorf("initial embedding was not used (error %v)", err)
+	}
+	tsne.InitialY = randomData(10, 2)
+	if _, err := tsne.TryEmbedData(X, nil); err == nil {
+		t.Error("expected an error for an initial embedding of the wrong size")
+	}
+}
+
+// TestPCAInitializationLowRank verifies that the dimensions of a PCA initialization beyond the rank of the data
+// are filled with small random values, so that the optimization can move them.
+func TestPCAInitializationLowRank(t *testing.T) {
+
+	X := randomData(40, 2)
+	for _, fromData := range []bool{true, false} {
+		tsne := NewTSNE(3, 5, 100, 50, false)
+		tsne.Init = InitPCA
+		tsne.Seed = 1
+		tsne.n = 40
+		tsne.initRand()
+		var Y0 *mat.Dense
+		if fromData {
+			Y0 = tsne.initialEmbedding(X, nil)
+		} else {
+			Y0 = tsne.initialEmbedding(nil, SquaredDistanceMatrix(X))
+		}
+		std := stat.StdDev(mat.Col(nil, 2, Y0), nil)
+		if std == 0 || std > InitialStandardDeviation/10 {
+			t.Errorf("fromData=%v: third dimension has standard deviation %v, expected small random values", fromData, std)
+		}
+		var Y mat.Matrix
+		var err error
+		if fromData {
+			Y, err = tsne.TryEmbedData(X, nil)
+		} else {
+			Y, err = tsne.TryEmbedDistances(SquaredDistanceMatrix(X), nil)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if moved := stat.StdDev(mat.Col(nil, 2, Y), nil); moved <= std {
+			t.Errorf("fromData=%v: third dimension did not move, standard deviation %v", fromData, moved)
+		}
+	}
+}
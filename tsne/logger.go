@@ -0,0 +1,81 @@
+// Copyright (c) 2018 Daniel Augusto Rizzi Salvadori. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found in the LICENSE file.
+
+package tsne
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Logger receives the progress messages of t-SNE, each with alternating keys and values as in log/slog,
+// which *slog.Logger satisfies. The affinity progress, optimizer phase changes and the end of the optimization
+// are logged at the Info level, the divergence and gradient norm of every iteration at the Debug level,
+// and problems such as unconverged perplexity calibrations at the Warn level.
+// It must be safe for concurrent use, since the affinities are computed in parallel.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+}
+
+// stdoutLogger is the logger used when Verbose is set and no Logger is provided.
+var stdoutLogger Logger = &writerLogger{w: os.Stdout}
+
+// logger returns the configured logger, stdoutLogger if Verbose is set, or a logger that discards everything.
+func (tsne *TSNE) logger() Logger {
+
+	if tsne.Logger != nil {
+		return tsne.Logger
+	}
+	if tsne.Verbose {
+		return stdoutLogger
+	}
+	return nopLogger{}
+}
+
+// writerLogger writes messages at the Info and Warn levels to w, one per line,
+// as the message followed by its key=value pairs. Debug messages are discarded.
+type writerLogger struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// Debug discards the message.
+func (l *writerLogger) Debug(msg string, args ...interface{}) {}
+
+// Info writes the message.
+func (l *writerLogger) Info(msg string, args ...interface{}) {
+
+	l.write(msg, args)
+}
+
+// Warn writes the message, prefixed by "WARN".
+func (l *writerLogger) Warn(msg string, args ...interface{}) {
+
+	l.write("WARN "+msg, args)
+}
+
+// write writes the message followed by its key=value pairs.
+func (l *writerLogger) write(msg string, args []interface{}) {
+
+	var b strings.Builder
+	b.WriteString(msg)
+	for k := 0; k+1 < len(args); k += 2 {
+		fmt.Fprintf(&b, " %v=%v", args[k], args[k+1])
+	}
+	b.WriteString("\n")
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	io.WriteString(l.w, b.String())
+}
+
+// nopLogger discards all messages.
+type nopLogger struct{}
+
+func (nopLogger) Debug(msg string, args ...interface{}) {}
+func (nopLogger) Info(msg string, args ...interface{})  {}
+func (nopLogger) Warn(msg string, args ...interface{})  {}
@@ -0,0 +1,99 @@
+// Copyright (c) 2018 Daniel Augusto Rizzi Salvadori. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found in the LICENSE file.
+
+package tsne
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// recordingLogger records the messages it receives, prefixed by their level.
+type recordingLogger struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (l *recordingLogger) record(level, msg string) {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.messages = append(l.messages, level+" "+msg)
+}
+
+func (l *recordingLogger) Debug(msg string, args ...interface{}) { l.record("DEBUG", msg) }
+func (l *recordingLogger) Info(msg string, args ...interface{})  { l.record("INFO", msg) }
+func (l *recordingLogger) Warn(msg string, args ...interface{})  { l.record("WARN", msg) }
+
+// count returns the number of recorded messages equal to message.
+func (l *recordingLogger) count(message string) int {
+
+	c := 0
+	for _, m := range l.messages {
+		if m == message {
+			c++
+		}
+	}
+	return c
+}
+
+// TestLogger verifies that the affinity progress, the optimizer phase changes and every iteration are logged.
+func TestLogger(t *testing.T) {
+
+	log := &recordingLogger{}
+	cfg := DefaultConfig()
+	cfg.Perplexity = 5
+	cfg.MaxIter = 120
+	cfg.ExaggerationIters = 50
+	cfg.MomentumSwitchIter = 50
+	cfg.Seed = 1
+	cfg.Logger = log
+	tsne, _ := New(cfg)
+	if _, err := tsne.TryEmbedData(randomData(50, 3), nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		message string
+		count   int
+	}{
+		{"INFO computing affinities", 1},
+		{"INFO optimizing embedding", 1},
+		{"INFO early exaggeration ended", 1},
+		{"INFO momentum switched", 1},
+		{"DEBUG iteration", cfg.MaxIter},
+		{"INFO optimization stopped", 1},
+	} {
+		if got := log.count(c.message); got != c.count {
+			t.Errorf("logged %q %d times, expected %d", c.message, got, c.count)
+		}
+	}
+}
+
+// TestWriterLogger verifies the format of the messages written when Verbose is set.
+func TestWriterLogger(t *testing.T) {
+
+	var buf bytes.Buffer
+	log := &writerLogger{w: &buf}
+	log.Debug("iteration", "iteration", 1)
+	log.Info("optimization stopped", "reason", StopMaxIter, "iterations", 10)
+	log.Warn("perplexity calibration did not converge", "unconverged", 2, "points", 5)
+	expected := fmt.Sprintln("optimization stopped reason=max iterations iterations=10") +
+		fmt.Sprintln("WARN perplexity calibration did not converge unconverged=2 points=5")
+	if got := buf.String(); got != expected {
+		t.Errorf("wrote %q, expected %q", got, expected)
+	}
+	cfg := DefaultConfig()
+	if tsne, _ := New(cfg); tsne.logger() != (nopLogger{}) {
+		t.Error("expected the messages to be discarded by default")
+	}
+	cfg.Verbose = true
+	if tsne, _ := New(cfg); tsne.logger() != stdoutLogger {
+		t.Error("expected the messages to be written to stdout when Verbose is set")
+	}
+	cfg.Logger = &recordingLogger{}
+	if tsne, _ := New(cfg); tsne.logger() != cfg.Logger {
+		t.Error("expected the configured Logger to take precedence over Verbose")
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright (c) 2018 Daniel Augusto Rizzi Salvadori. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found in the LICENSE file.
+
+package tsne
+
+import (
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Metric computes the distance between two datapoints of the same dimension.
+// Distances must be non-negative, and zero between identical datapoints.
+// Only the metrics provided by this package are called concurrently, so other metrics need not be safe for concurrent use.
+type Metric interface {
+	Distance(a, b []float64) float64
+}
+
+// MetricFunc is an adapter to allow the use of ordinary functions as metrics.
+type MetricFunc func(a, b []float64) float64
+
+// Distance returns f(a, b).
+func (f MetricFunc) Distance(a, b []float64) float64 {
+
+	return f(a, b)
+}
+
+// Metrics provided by this package.
+var (
+	SquaredEuclidean Metric = builtinMetric(squaredEuclidean) // Squared euclidean distance, as in classic t-SNE
+	Euclidean        Metric = builtinMetric(euclidean)        // Euclidean distance
+	Manhattan        Metric = builtinMetric(manhattan)        // Sum of absolute differences
+	Chebyshev        Metric = builtinMetric(chebyshev)        // Largest absolute difference
+	Cosine           Metric = builtinMetric(cosine)           // One minus the cosine of the angle between the datapoints
+	Correlation      Metric = builtinMetric(correlation)      // One minus the Pearson correlation between the datapoints
+	Hamming          Metric = builtinMetric(hamming)          // Fraction of coordinates that differ
+)
+
+// builtinMetric identifies one of the metrics provided by this package.
+type builtinMetric int
+
+const (
+	squaredEuclidean builtinMetric = iota
+	euclidean
+	manhattan
+	chebyshev
+	cosine
+	correlation
+	hamming
+)
+
+var builtinMetricNames = []string{"squared-euclidean", "euclidean", "manhattan", "chebyshev", "cosine", "correlation", "hamming"}
+
+// String returns the name of the metric.
+func (m builtinMetric) String() string {
+
+	return builtinMetricNames[m]
+}
+
+// metricByName returns the metric provided by this package with the specified name, or nil if there is none.
+func metricByName(name string) Metric {
+
+	for i, n := range builtinMetricNames {
+		if n == name {
+			return builtinMetric(i)
+		}
+	}
+	return nil
+}
+
+// Distance returns the distance between a and b.
+func (m builtinMetric) Distance(a, b []float64) float64 {
+
+	switch m {
+	case euclidean:
+		return math.Sqrt(sqDist(a, b))
+	case manhattan:
+		var dist float64
+		for k := range a {
+			dist += math.Abs(a[k] - b[k])
+		}
+		return dist
+	case chebyshev:
+		var dist float64
+		for k := range a {
+			dist = math.Max(dist, math.Abs(a[k]-b[k]))
+		}
+		return dist
+	case cosine:
+		return cosineDistance(a, b, 0, 0)
+	case correlation:
+		var meanA, meanB float64
+		for k := range a {
+			meanA += a[k] / float64(len(a))
+			meanB += b[k] / float64(len(b))
+		}
+		return cosineDistance(a, b, meanA, meanB)
+	case hamming:
+		if len(a) == 0 {
+			return 0
+		}
+		var count int
+		for k := range a {
+			if a[k] != b[k] {
+				count++
+			}
+		}
+		return float64(count) / float64(len(a))
+	default:
+		return sqDist(a, b)
+	}
+}
+
+// cosineDistance returns one minus the cosine of the angle between a and b after subtracting meanA and meanB from them.
+// If either of them is zero, the distance is zero if both are, and one otherwise.
+func cosineDistance(a, b []float64, meanA, meanB float64) float64 {
+
+	var dot, normA, normB float64
+	for k := range a {
+		x, y := a[k]-meanA, b[k]-meanB
+		dot += x * y
+		normA += x * x
+		normB += y * y
+	}
+	if normA == 0 || normB == 0 {
+		if normA == normB {
+			return 0
+		}
+		return 1
+	}
+	return math.Max(1-dot/math.Sqrt(normA*normB), 0)
+}
+
+// metric returns the configured metric, or SquaredEuclidean if there is none.
+func (tsne *TSNE) metric() Metric {
+
+	if tsne.Metric == nil {
+		return SquaredEuclidean
+	}
+	return tsne.Metric
+}
+
+// metricWorkers returns the number of goroutines that may compute distances with the metric at once:
+// the configured number for the metrics provided by this package, and one for any other.
+func (tsne *TSNE) metricWorkers(metric Metric) int {
+
+	if isBuiltinMetric(metric) {
+		return tsne.workers()
+	}
+	return 1
+}
+
+// isBuiltinMetric returns whether the metric is one of the metrics provided by this package.
+func isBuiltinMetric(metric Metric) bool {
+
+	_, ok := metric.(builtinMetric)
+	return ok
+}
+
+// DistanceMatrix computes the matrix of distances between the row vectors of X according to the specified metric.
+// Returns a matrix where the {i, j}-th element is the distance between the i-th and j-th rows in X.
+func DistanceMatrix(X mat.Matrix, metric Metric) mat.Matrix {
+
+	n, _ := X.Dims()
+	Xd := mat.DenseCopyOf(X)
+	D := mat.NewDense(n, n, nil)
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			dist := metric.Distance(Xd.RawRowView(i), Xd.RawRowView(j))
+			D.Set(i, j, dist)
+			D.Set(j, i, dist)
+		}
+	}
+	return D
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2018 Daniel Augusto Rizzi Salvadori. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found in the LICENSE file.
+
+package tsne
+
+import (
+	"math"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// TestMetrics verifies the distances computed by the metrics provided by this package.
+func TestMetrics(t *testing.T) {
+
+	a := []float64{1, 2, 3, 4}
+	b := []float64{2, 4, 3, 0}
+	tests := []struct {
+		metric Metric
+		dist   float64
+	}{
+		{SquaredEuclidean, 21},
+		{Euclidean, math.Sqrt(21)},
+		{Manhattan, 7},
+		{Chebyshev, 4},
+		{Cosine, 1 - 19/math.Sqrt(30*29)},
+		{Correlation, 1 + 3.5/math.Sqrt(5*8.75)},
+		{Hamming, 0.75},
+	}
+	for _, test := range tests {
+		if dist := test.metric.Distance(a, b); math.Abs(dist-test.dist) > 1e-12 {
+			t.Errorf("%v distance is %v, expected %v", test.metric, dist, test.dist)
+		}
+		if dist := test.metric.Distance(a, a); math.Abs(dist) > 1e-12 {
+			t.Errorf("%v distance between identical points is %v", test.metric, dist)
+		}
+		if m := metricByName(test.metric.(builtinMetric).String()); m != test.metric {
+			t.Errorf("metric named %v not found", test.metric)
+		}
+	}
+}
+
+// TestEmbedDataMetric verifies that the configured metric is used to compute the input affinities.
+func TestEmbedDataMetric(t *testing.T) {
+
+	X := randomData(30, 3)
+	for _, sparse := range []bool{false, true} {
+		var calls int
+		cfg := DefaultConfig()
+		cfg.Perplexity = 5
+		cfg.MaxIter = 10
+		cfg.Sparse = sparse
+		cfg.Seed = 1
+		cfg.Metric = MetricFunc(func(a, b []float64) float64 {
+			calls++
+			return Manhattan.Distance(a, b)
+		})
+		tsne, _ := New(cfg)
+		if _, err := tsne.TryEmbedData(X, nil); err != nil {
+			t.Fatal(err)
+		}
+		if calls == 0 {
+			t.Errorf("custom metric was not used (sparse %v)", sparse)
+		}
+		// The affinities must match those computed from the Manhattan distance matrix
+		cfg.Metric = nil
+		ref, _ := New(cfg)
+		if _, err := ref.TryEmbedDistances(DistanceMatrix(X, Manhattan), nil); err != nil {
+			t.Fatal(err)
+		}
+		if sparse {
+			if !mat.EqualApprox(denseOf(tsne.PSparse), denseOf(ref.PSparse), 1e-12) {
+				t.Error("sparse affinities differ from those of the distance matrix")
+			}
+		} else if !mat.EqualApprox(tsne.P, ref.P, 1e-12) {
+			t.Error("affinities differ from those of the distance matrix")
+		}
+	}
+}
+
+// denseOf returns a dense copy of the sparse matrix S.
+func denseOf(S *SparseMatrix) *mat.Dense {
+
+	n, _ := S.Dims()
+	D := mat.NewDense(n, n, nil)
+	for i := 0; i < n; i++ {
+		for e := S.RowPtr[i]; e < S.RowPtr[i+1]; e++ {
+			D.Set(i, S.ColIdx[e], S.Val[e])
+		}
+	}
+	return D
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2018 Daniel Augusto Rizzi Salvadori. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found in the LICENSE file.
+
+package tsne
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// EmbedNeighbors runs t-SNE on a precomputed nearest neighbor graph, such as one obtained from an approximate
+// nearest neighbor index, without ever computing an n by n distance matrix. indices[i] holds the neighbors of the
+// i-th point and distances[i] the (squared) distances to them, which play the role of the rows of D in EmbedDistances.
+// Every point must have the same number k of distinct neighbors, in any order, excluding the point itself.
+// The affinities are calibrated over the neighbors of each point as with Sparse, to the configured perplexity
+// (or k if it is smaller), and stored in PSparse.
+// It returns an error wrapping ErrEmptyInput, ErrTooFewPoints, ErrShape, ErrNonFinite, ErrNegativeDistance, ErrDimsOut,
+// ErrMaxIter or ErrConfig if the input or parameters are invalid. Errors concerning a specific distance are of type *ElementError,
+// with the index of the point as Row and the position of the neighbor as Col.
+// Since there is no data to initialize the embedding from, InitPCA is not supported.
+func (tsne *TSNE) EmbedNeighbors(indices [][]int, distances [][]float64, stepFunc func(iter int, divergence float64, embedding mat.Matrix) bool) (mat.Matrix, error) {
+
+	return tsne.EmbedNeighborsContext(context.Background(), indices, distances, stepFunc)
+}
+
+// EmbedNeighborsContext is like EmbedNeighbors, but it stops as soon as ctx is done, both while computing
+// the input affinities and during the optimization. In that case it returns ctx.Err() along with
+// the embedding optimized so far, which is nil if the optimization had not started.
+func (tsne *TSNE) EmbedNeighborsContext(ctx context.Context, indices [][]int, distances [][]float64, stepFunc func(iter int, divergence float64, embedding mat.Matrix) bool) (mat.Matrix, error) {
+
+	if err := validateNeighbors(indices, distances); err != nil {
+		return nil, err
+	}
+	if err := tsne.validateParamsWithoutData(len(indices)); err != nil {
+		return nil, err
+	}
+	tsne.Y = nil
+	err := tsne.embedNeighbors(ctx, indices, distances, stepFunc)
+	return tsne.embedding(), err
+}
+
+// embedNeighbors computes the sparse input affinities from the neighbor graph and runs t-SNE.
+// It returns ctx.Err() if ctx is done before finishing.
+func (tsne *TSNE) embedNeighbors(ctx context.Context, indices [][]int, distances [][]float64, stepFunc func(iter int, divergence float64, embedding mat.Matrix) bool) error {
+
+	tsne.n = len(indices)
+	tsne.data = nil
+	tsne.initRand()
+	start := time.Now()
+	// Flatten the neighbor graph as returned by nearestNeighbors
+	k := len(indices[0])
+	idx := make([]int, 0, tsne.n*k)
+	dist := make([]float64, 0, tsne.n*k)
+	for i := range indices {
+		idx = append(idx, indices[i]...)
+		dist = append(dist, distances[i]...)
+	}
+	perplexity := math.Min(tsne.Perplexity, float64(k))
+	if err := tsne.knn2p(ctx, idx, dist, k, EntropyTolerance, perplexity); err != nil {
+		return err
+	}
+	tsne.affinityTime = time.Since(start)
+	tsne.initSolution(nil, nil)
+	return tsne.run(ctx, stepFunc)
+}
+
+// validateNeighbors verifies that every point has the same positive number of distinct neighbors other than itself,
+// with as many distances, and that the distances are finite and non-negative.
+func validateNeighbors(indices [][]int, distances [][]float64) error {
+
+	n := len(indices)
+	if err := validateCount(n); err != nil {
+		return err
+	}
+	if len(distances) != n {
+		return fmt.Errorf("%w: distances of %d points, expected %d", ErrShape, len(distances), n)
+	}
+	k := len(indices[0])
+	if k == 0 {
+		return fmt.Errorf("%w: points have no neighbors", ErrShape)
+	}
+	seen := make([]int, n) // Last point (plus one) that has each point as a neighbor
+	for i := range indices {
+		if len(indices[i]) != k || len(distances[i]) != k {
+			return fmt.Errorf("%w: point %d has %d neighbors and %d distances, expected %d", ErrShape, i, len(indices[i]), len(distances[i]), k)
+		}
+		for c, j := range indices[i] {
+			if j < 0 || j >= n || j == i || seen[j] == i+1 {
+				return fmt.Errorf("%w: neighbor %d of point %d is out of range, the point itself or repeated", ErrShape, j, i)
+			}
+			seen[j] = i + 1
+			if v := distances[i][c]; math.IsNaN(v) || math.IsInf(v, 0) {
+				return &ElementError{Row: i, Col: c, Value: v, Err: ErrNonFinite}
+			} else if v < 0 {
+				return &ElementError{Row: i, Col: c, Value: v, Err: ErrNegativeDistance}
+			}
+		}
+	}
+	return nil
+}
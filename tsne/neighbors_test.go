@@ -0,0 +1,81 @@
+// Copyright (c) 2018 Daniel Augusto Rizzi Salvadori. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found in the LICENSE file.
+
+package tsne
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// TestEmbedNeighbors verifies that embedding the nearest neighbor graph of the data reproduces the sparse embedding of the data.
+func TestEmbedNeighbors(t *testing.T) {
+
+	X := randomData(60, 4)
+	cfg := DefaultConfig()
+	cfg.Perplexity = 5
+	cfg.MaxIter = 100
+	cfg.Sparse = true
+	cfg.Seed = 1
+	fromData, _ := New(cfg)
+	Y, err := fromData.TryEmbedData(X, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := fromData.numNeighbors()
+	idx, dist, _ := fromData.sparseDataNeighbors(context.Background(), mat.DenseCopyOf(X))
+	indices := make([][]int, 60)
+	distances := make([][]float64, 60)
+	for i := range indices {
+		indices[i] = idx[i*k : (i+1)*k]
+		distances[i] = dist[i*k : (i+1)*k]
+	}
+	cfg.Sparse = false
+	fromNeighbors, _ := New(cfg)
+	Yn, err := fromNeighbors.EmbedNeighbors(indices, distances, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !mat.Equal(Y, Yn) {
+		t.Error("embedding of the neighbors differs from the sparse embedding of the data")
+	}
+	if fromNeighbors.P != nil || fromNeighbors.PSparse.NNZ() != fromData.PSparse.NNZ() {
+		t.Error("expected the affinities of the neighbors to be sparse")
+	}
+}
+
+// TestEmbedNeighborsErrors verifies that invalid neighbor graphs and configurations are rejected.
+func TestEmbedNeighborsErrors(t *testing.T) {
+
+	valid := [][]int{{1}, {2}, {0}}
+	ones := [][]float64{{1}, {1}, {1}}
+	for _, c := range []struct {
+		name      string
+		indices   [][]int
+		distances [][]float64
+		init      Initialization
+		err       error
+	}{
+		{"empty", nil, nil, InitRandom, ErrEmptyInput},
+		{"missing distances", valid, ones[:2], InitRandom, ErrShape},
+		{"no neighbors", [][]int{{}, {}}, [][]float64{{}, {}}, InitRandom, ErrShape},
+		{"different k", [][]int{{1, 2}, {2}, {0}}, [][]float64{{1, 1}, {1}, {1}}, InitRandom, ErrShape},
+		{"out of range", [][]int{{1}, {3}, {0}}, ones, InitRandom, ErrShape},
+		{"itself", [][]int{{1}, {1}, {0}}, ones, InitRandom, ErrShape},
+		{"repeated", [][]int{{1, 1}, {2, 0}, {0, 1}}, [][]float64{{1, 1}, {1, 1}, {1, 1}}, InitRandom, ErrShape},
+		{"NaN", valid, [][]float64{{1}, {math.NaN()}, {1}}, InitRandom, ErrNonFinite},
+		{"negative", valid, [][]float64{{1}, {1}, {-1}}, InitRandom, ErrNegativeDistance},
+		{"PCA", valid, ones, InitPCA, ErrConfig},
+	} {
+		cfg := DefaultConfig()
+		cfg.Init = c.init
+		tsne, _ := New(cfg)
+		if Y, err := tsne.EmbedNeighbors(c.indices, c.distances, nil); !errors.Is(err, c.err) || Y != nil {
+			t.Errorf("%s: got error %v, expected %v", c.name, err, c.err)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2018 Daniel Augusto Rizzi Salvadori. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found in the LICENSE file.
+
+package tsne
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// workers returns the number of goroutines to use for parallel computations.
+func (tsne *TSNE) workers() int {
+
+	if tsne.Workers > 0 {
+		return tsne.Workers
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// parallelFor calls f(w, i) for each i in [0, n) using the specified number of goroutines,
+// where w in [0, workers) identifies the goroutine so that f can use per-goroutine buffers.
+// The indices are handed out one at a time, so f must not depend on the order in which they are processed.
+// It stops early and returns ctx.Err() if ctx is done.
+func parallelFor(ctx context.Context, n, workers int, f func(w, i int)) error {
+
+	if workers > n {
+		workers = n
+	}
+	if workers <= 1 {
+		for i := 0; i < n; i++ {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			f(0, i)
+		}
+		return nil
+	}
+	var next int64 = -1
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func(w int) {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= n {
+					return
+				}
+				f(w, i)
+			}
+		}(w)
+	}
+	wg.Wait()
+	return ctx.Err()
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2018 Daniel Augusto Rizzi Salvadori. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found in the LICENSE file.
+
+package tsne
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// TestParallelAffinities verifies that the input affinities do not depend on the number of workers.
+func TestParallelAffinities(t *testing.T) {
+
+	X := randomData(100, 4)
+	for _, sparse := range []bool{false, true} {
+		var P []mat.Matrix
+		for _, workers := range []int{1, 3, 8} {
+			cfg := DefaultConfig()
+			cfg.Perplexity = 10
+			cfg.MaxIter = 0
+			cfg.Sparse = sparse
+			cfg.Workers = workers
+			tsne, _ := New(cfg)
+			if _, err := tsne.TryEmbedData(X, nil); err != nil {
+				t.Fatal(err)
+			}
+			if sparse {
+				P = append(P, denseOf(tsne.PSparse))
+			} else {
+				P = append(P, tsne.P)
+			}
+		}
+		for w := 1; w < len(P); w++ {
+			if !mat.Equal(P[0], P[w]) {
+				t.Errorf("affinities computed in parallel differ from the serial ones (sparse %v)", sparse)
+			}
+		}
+	}
+}
+
+// TestParallelGradient verifies that the divergence and gradient computed by each engine do not depend on the number of workers.
+func TestParallelGradient(t *testing.T) {
+
+	X := randomData(80, 3)
+	engines := []struct {
+		name   string
+		theta  float64
+		sparse bool
+	}{{"exact", 0, false}, {"exact sparse", 0, true}, {"Barnes-Hut", 0.5, false}, {"Barnes-Hut sparse", 0.5, true}}
+	for _, engine := range engines {
+		var divergence []float64
+		var grad []*mat.Dense
+		for _, workers := range []int{1, 2, 7} {
+			cfg := DefaultConfig()
+			cfg.Perplexity = 10
+			cfg.MaxIter = 20
+			cfg.Theta = engine.theta
+			cfg.Sparse = engine.sparse
+			cfg.Workers = workers
+			cfg.Seed = 1
+			tsne, _ := New(cfg)
+			if _, err := tsne.TryEmbedData(X, nil); err != nil {
+				t.Fatal(err)
+			}
+			divergence = append(divergence, tsne.gradient(1))
+			grad = append(grad, mat.DenseCopyOf(tsne.dCdY))
+		}
+		for w := 1; w < len(grad); w++ {
+			if divergence[w] != divergence[0] || !mat.Equal(grad[w], grad[0]) {
+				t.Errorf("%s gradient computed in parallel differs from the serial one", engine.name)
+			}
+		}
+	}
+}
+
+// TestParallelForCancel verifies that parallelFor stops when the context is canceled.
+func TestParallelForCancel(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := make([]int, 4)
+	err := parallelFor(ctx, 1000, 4, func(w, i int) {
+		calls[w]++
+		if i == 10 {
+			cancel()
+		}
+	})
+	var total int
+	for _, c := range calls {
+		total += c
+	}
+	if !errors.Is(err, context.Canceled) || total == 1000 {
+		t.Errorf("parallelFor was not canceled: %d calls, error %v", total, err)
+	}
+}
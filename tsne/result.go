@@ -0,0 +1,71 @@
+// Copyright (c) 2018 Daniel Augusto Rizzi Salvadori. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found in the LICENSE file.
+
+package tsne
+
+import (
+	"math"
+	"time"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Result holds an embedding along with diagnostics of how it was obtained.
+type Result struct {
+	Y          mat.Matrix // The embedding (nil if the optimization has not started)
+	Divergence float64    // KL divergence at the last iteration (zero if no iterations were performed)
+	History    []float64  // KL divergence at each iteration
+	Iterations int        // Number of iterations performed
+	StopReason StopReason // Reason why the optimization stopped
+
+	AffinityTime     time.Duration // Time spent computing the input affinities
+	OptimizationTime time.Duration // Time spent optimizing the embedding (accumulated over Resume calls)
+
+	// Beta holds the precision of the Gaussian kernel of each datapoint, as calibrated to the perplexity,
+	// such that its affinities are proportional to exp(-beta*d) for its (squared) distances d.
+	// Sigma holds the corresponding bandwidths, sqrt(1/(2*beta)), which are in units of the data when using
+	// the default squared euclidean distance. They are nil if the affinities were not calibrated to the perplexity.
+	Beta  []float64
+	Sigma []float64
+
+	// Entropy holds the entropy (in nats) of the conditional distribution of each datapoint over the others,
+	// and Perplexity the corresponding perplexity exp(Entropy), which should be close to the configured perplexity.
+	// Unconverged lists the datapoints for which the calibration did not reach the configured perplexity within
+	// MaxBinarySearchSteps steps, which typically happens for duplicated datapoints and outliers.
+	Entropy     []float64
+	Perplexity  []float64
+	Unconverged []int
+}
+
+// Result returns the current embedding and the diagnostics of the last optimization.
+// The slices of the result are copies, which are not modified by further optimization.
+func (tsne *TSNE) Result() Result {
+
+	r := Result{
+		Y:                tsne.embedding(),
+		History:          append([]float64(nil), tsne.history...),
+		Iterations:       tsne.iter,
+		StopReason:       tsne.stopReason,
+		AffinityTime:     tsne.affinityTime,
+		OptimizationTime: tsne.optimizationTime,
+		Beta:             append([]float64(nil), tsne.beta...),
+		Entropy:          append([]float64(nil), tsne.entropy...),
+		Unconverged:      append([]int(nil), tsne.unconverged...),
+	}
+	if len(r.History) > 0 {
+		r.Divergence = r.History[len(r.History)-1]
+	}
+	if r.Beta != nil {
+		r.Sigma = make([]float64, len(r.Beta))
+		for i, beta := range r.Beta {
+			r.Sigma[i] = math.Sqrt(1 / (2 * beta))
+		}
+	}
+	if r.Entropy != nil {
+		r.Perplexity = make([]float64, len(r.Entropy))
+		for i, H := range r.Entropy {
+			r.Perplexity[i] = math.Exp(H)
+		}
+	}
+	return r
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2018 Daniel Augusto Rizzi Salvadori. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found in the LICENSE file.
+
+package tsne
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// TestResult verifies the diagnostics reported in the result of an embedding, and that they are checkpointed.
+func TestResult(t *testing.T) {
+
+	X := randomData(50, 4)
+	for _, sparse := range []bool{false, true} {
+		cfg := DefaultConfig()
+		cfg.Perplexity = 10
+		cfg.MaxIter = 30
+		cfg.Sparse = sparse
+		cfg.Seed = 1
+		tsne, _ := New(cfg)
+		var divergence []float64
+		Y, err := tsne.TryEmbedData(X, func(iter int, div float64, Y mat.Matrix) bool {
+			divergence = append(divergence, div)
+			return false
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := tsne.Result()
+		if r.Y != Y || r.Iterations != 30 || r.StopReason != StopMaxIter {
+			t.Errorf("result has %d iterations, stop reason %v, expected 30 and %v", r.Iterations, r.StopReason, StopMaxIter)
+		}
+		if !reflect.DeepEqual(r.History, divergence) || r.Divergence != divergence[29] {
+			t.Errorf("result history %v differs from the divergences %v", r.History, divergence)
+		}
+		if r.AffinityTime <= 0 || r.OptimizationTime <= 0 {
+			t.Errorf("timings are %v and %v, expected positive durations", r.AffinityTime, r.OptimizationTime)
+		}
+		if len(r.Beta) != 50 || len(r.Sigma) != 50 {
+			t.Fatalf("result has %d precisions and %d bandwidths, expected 50", len(r.Beta), len(r.Sigma))
+		}
+		for i := range r.Beta {
+			if !(r.Beta[i] > 0) || math.Abs(2*r.Beta[i]*r.Sigma[i]*r.Sigma[i]-1) > 1e-12 {
+				t.Fatalf("point %d has precision %v and bandwidth %v", i, r.Beta[i], r.Sigma[i])
+			}
+		}
+		if len(r.Unconverged) > 0 || len(r.Perplexity) != 50 {
+			t.Fatalf("calibration of %d points did not converge, got %d perplexities", len(r.Unconverged), len(r.Perplexity))
+		}
+		for i, perplexity := range r.Perplexity {
+			if math.Abs(perplexity-10) > 1e-3 || math.Abs(math.Log(perplexity)-r.Entropy[i]) > 1e-12 {
+				t.Fatalf("point %d has perplexity %v and entropy %v, expected perplexity 10", i, perplexity, r.Entropy[i])
+			}
+		}
+		// The diagnostics must survive a checkpoint
+		var buf bytes.Buffer
+		if err := tsne.Save(&buf); err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := Load(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lr := loaded.Result()
+		lr.Y, r.Y = nil, nil
+		if !reflect.DeepEqual(lr, r) {
+			t.Errorf("result of the loaded embedding differs:\n%+v\n%+v", lr, r)
+		}
+	}
+}
+
+// TestUnconvergedCalibration verifies that datapoints with too many duplicates to reach the perplexity are reported.
+func TestUnconvergedCalibration(t *testing.T) {
+
+	X := randomData(40, 3)
+	for i := 1; i < 20; i++ {
+		X.SetRow(i, X.RawRowView(0))
+	}
+	for _, sparse := range []bool{false, true} {
+		cfg := DefaultConfig()
+		cfg.Perplexity = 5
+		cfg.MaxIter = 0
+		cfg.Sparse = sparse
+		tsne, _ := New(cfg)
+		if _, err := tsne.TryEmbedData(X, nil); err != nil {
+			t.Fatal(err)
+		}
+		// Points whose nearest neighbors are the duplicates may not converge either
+		r := tsne.Result()
+		if len(r.Unconverged) < 20 || r.Unconverged[0] != 0 || r.Unconverged[19] != 19 {
+			t.Errorf("unconverged points are %v, expected to include the 20 duplicates (sparse %v)", r.Unconverged, sparse)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2018 Daniel Augusto Rizzi Salvadori. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found in the LICENSE file.
+
+package tsne
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+// rpTree is a random projection tree over the rows of a data matrix, stored by its leaves.
+// Each node projects its points onto the direction between two of them picked at random,
+// and splits them at the median projection, until the leaves have at most a maximum number of points.
+type rpTree struct {
+	order  []int // Points in leaf order: the leaf with index l holds order[start[l]:start[l+1]]
+	start  []int // Start of each leaf in order, followed by the number of points
+	leafOf []int // Leaf of each point
+}
+
+// newRPTree builds a random projection tree over the rows of X with leaves of at most leafSize points,
+// using rnd to pick the projection directions.
+func newRPTree(X *mat.Dense, leafSize int, rnd *rand.Rand) *rpTree {
+
+	n, d := X.Dims()
+	t := &rpTree{order: make([]int, n), leafOf: make([]int, n)}
+	for i := range t.order {
+		t.order[i] = i
+	}
+	proj := make([]float64, n)
+	dir := make([]float64, d)
+	var split func(lo, hi int)
+	split = func(lo, hi int) {
+		if hi-lo <= leafSize {
+			for _, i := range t.order[lo:hi] {
+				t.leafOf[i] = len(t.start)
+			}
+			t.start = append(t.start, lo)
+			return
+		}
+		// Project onto the direction between two random points, and split at the median (even for duplicates)
+		a := t.order[lo+rnd.Intn(hi-lo)]
+		b := t.order[lo+rnd.Intn(hi-lo)]
+		floats.SubTo(dir, X.RawRowView(a), X.RawRowView(b))
+		for e := lo; e < hi; e++ {
+			proj[e] = floats.Dot(dir, X.RawRowView(t.order[e]))
+		}
+		sort.Sort(byDistance{t.order[lo:hi], proj[lo:hi]})
+		mid := (lo + hi) / 2
+		split(lo, mid)
+		split(mid, hi)
+	}
+	split(0, n)
+	t.start = append(t.start, n)
+	return t
+}
+
+// leaf returns the points in the leaf of the i-th point.
+func (t *rpTree) leaf(i int) []int {
+
+	l := t.leafOf[i]
+	return t.order[t.start[l]:t.start[l+1]]
+}
+
+// rpForestNeighbors finds approximations of the k nearest neighbors of each of the rows of X according to the metric,
+// among the points sharing a leaf with it in any of the trees of a random projection forest.
+// The trees are built in parallel, and so are the points queried if the metric allows it (see metricWorkers).
+// It returns the same flattened matrices as nearestNeighbors, or ctx.Err() if ctx is done before finishing.
+func (tsne *TSNE) rpForestNeighbors(ctx context.Context, X *mat.Dense, k int, metric Metric) ([]int, []float64, error) {
+
+	n, _ := X.Dims()
+	// Since the median splits leave more than half of leafSize points in each leaf,
+	// leaves of up to 2k + 1 points ensure that every point has at least k candidates
+	leafSize := tsne.RPLeafSize
+	if leafSize < 2*k+1 {
+		leafSize = 2*k + 1
+	}
+	// Draw the seeds of the trees from the random number generator of the embedding, so that they can be built in parallel
+	tsne.forestSeeds = make([]int64, tsne.RPTrees)
+	for t := range tsne.forestSeeds {
+		tsne.forestSeeds[t] = tsne.rng.Int63()
+	}
+	forest := make([]*rpTree, tsne.RPTrees)
+	err := parallelFor(ctx, len(forest), tsne.workers(), func(w, t int) {
+		forest[t] = newRPTree(X, leafSize, rand.New(rand.NewSource(tsne.forestSeeds[t])))
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	// Search the candidates of each point, visiting each of them once
+	idx := make([]int, n*k)
+	dists := make([]float64, n*k)
+	workers := tsne.metricWorkers(metric)
+	heaps := make([]*neighborHeap, workers)
+	seen := make([][]int, workers) // Last point (plus one) for which each point was a candidate
+	for w := range heaps {
+		heaps[w] = &neighborHeap{idx: make([]int, 0, k), dist: make([]float64, 0, k)}
+		seen[w] = make([]int, n)
+	}
+	err = parallelFor(ctx, n, workers, func(w, i int) {
+		xi := X.RawRowView(i)
+		for _, tree := range forest {
+			for _, j := range tree.leaf(i) {
+				if j != i && seen[w][j] != i+1 {
+					seen[w][j] = i + 1
+					heaps[w].add(j, metric.Distance(xi, X.RawRowView(j)), k)
+				}
+			}
+		}
+		heaps[w].sorted(idx[i*k:(i+1)*k], dists[i*k:(i+1)*k])
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return idx, dists, nil
+}
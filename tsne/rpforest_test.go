@@ -0,0 +1,153 @@
+// Copyright (c) 2018 Daniel Augusto Rizzi Salvadori. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found in the LICENSE file.
+
+package tsne
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// TestRPForestNeighbors verifies that the random projection forest finds most of the true nearest neighbors,
+// at their exact distances, and more of them with more trees.
+func TestRPForestNeighbors(t *testing.T) {
+
+	X := randomData(2000, 5)
+	n, k := 2000, 15
+	exactIdx, _, _ := nearestNeighbors(context.Background(), n, k, func(i, j int) float64 {
+		return sqDist(X.RawRowView(i), X.RawRowView(j))
+	})
+	prevRecall := 0.0
+	for _, trees := range []int{1, DefaultRPTrees} {
+		cfg := DefaultConfig()
+		cfg.Perplexity = 5
+		cfg.Sparse = true
+		cfg.Neighbors = NeighborsRPForest
+		cfg.RPTrees = trees
+		cfg.Seed = 1
+		tsne, _ := New(cfg)
+		tsne.n = n
+		tsne.initRand()
+		idx, dist, err := tsne.sparseDataNeighbors(context.Background(), X)
+		if err != nil {
+			t.Fatal(err)
+		}
+		found := 0
+		for i := 0; i < n; i++ {
+			row := idx[i*k : (i+1)*k]
+			if !sort.Float64sAreSorted(dist[i*k : (i+1)*k]) {
+				t.Fatalf("neighbors of point %d are not sorted", i)
+			}
+			for c, j := range row {
+				if j == i || dist[i*k+c] != sqDist(X.RawRowView(i), X.RawRowView(j)) {
+					t.Fatalf("neighbor %d of point %d is %d at %v", c, i, j, dist[i*k+c])
+				}
+			}
+			exact := map[int]bool{}
+			for _, j := range exactIdx[i*k : (i+1)*k] {
+				exact[j] = true
+			}
+			for _, j := range row {
+				if exact[j] {
+					found++
+				}
+			}
+		}
+		recall := float64(found) / float64(n*k)
+		if recall <= prevRecall {
+			t.Errorf("recall with %d trees is %v, no better than with fewer trees", trees, recall)
+		}
+		prevRecall = recall
+	}
+	if prevRecall < 0.95 {
+		t.Errorf("recall with the default number of trees is %v, expected at least 0.95", prevRecall)
+	}
+	cfg := DefaultConfig()
+	cfg.Neighbors = NeighborsRPForest
+	cfg.RPTrees = 0
+	if _, err := New(cfg); !errors.Is(err, ErrConfig) {
+		t.Errorf("expected ErrConfig without trees, got %v", err)
+	}
+	cfg = DefaultConfig()
+	cfg.Neighbors = NeighborsVPTree
+	cfg.Metric = Cosine
+	if _, err := New(cfg); !errors.Is(err, ErrConfig) {
+		t.Errorf("expected ErrConfig for a VP tree with the cosine distance, got %v", err)
+	}
+}
+
+// TestRPForestCustomMetric verifies that a metric other than those provided by this package is never called concurrently,
+// even with several workers.
+func TestRPForestCustomMetric(t *testing.T) {
+
+	X := randomData(200, 3)
+	var calls, active int
+	cfg := DefaultConfig()
+	cfg.Perplexity = 5
+	cfg.Sparse = true
+	cfg.Neighbors = NeighborsRPForest
+	cfg.Workers = 4
+	cfg.Metric = MetricFunc(func(a, b []float64) float64 {
+		active++
+		if active > 1 {
+			t.Error("metric called concurrently")
+		}
+		calls++
+		active--
+		return Euclidean.Distance(a, b)
+	})
+	tsne, _ := New(cfg)
+	tsne.n = 200
+	tsne.initRand()
+	if _, _, err := tsne.sparseDataNeighbors(context.Background(), X); err != nil {
+		t.Fatal(err)
+	}
+	if calls == 0 {
+		t.Error("custom metric was not used")
+	}
+}
+
+// TestRPForestSeed verifies that the random projection trees are drawn from the seed of the embedding,
+// and that their seeds are saved in checkpoints.
+func TestRPForestSeed(t *testing.T) {
+
+	X := randomData(100, 4)
+	embed := func(seed int64) *TSNE {
+		cfg := DefaultConfig()
+		cfg.Perplexity = 5
+		cfg.MaxIter = 10
+		cfg.Sparse = true
+		cfg.Neighbors = NeighborsRPForest
+		cfg.RPTrees = 2
+		cfg.Seed = seed
+		tsne, _ := New(cfg)
+		if _, err := tsne.TryEmbedData(X, nil); err != nil {
+			t.Fatal(err)
+		}
+		return tsne
+	}
+	a, b, c := embed(1), embed(1), embed(2)
+	if len(a.forestSeeds) != 2 || !reflect.DeepEqual(a.forestSeeds, b.forestSeeds) || !mat.Equal(a.Y, b.Y) {
+		t.Error("expected the same forest and embedding with the same seed")
+	}
+	if reflect.DeepEqual(a.forestSeeds, c.forestSeeds) {
+		t.Error("expected a different forest with a different seed")
+	}
+	var buf bytes.Buffer
+	if err := a.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.forestSeeds, a.forestSeeds) {
+		t.Errorf("loaded forest seeds %v, expected %v", loaded.forestSeeds, a.forestSeeds)
+	}
+}
@@ -0,0 +1,401 @@
+// Copyright (c) 2018 Daniel Augusto Rizzi Salvadori. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found in the LICENSE file.
+
+package tsne
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"math"
+	"sort"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// SparseMatrix is a square matrix stored in compressed sparse row (CSR) format.
+// The entries of row i are Val[RowPtr[i]:RowPtr[i+1]], in the columns ColIdx[RowPtr[i]:RowPtr[i+1]].
+// Column indices are sorted within each row.
+type SparseMatrix struct {
+	n      int
+	RowPtr []int
+	ColIdx []int
+	Val    []float64
+}
+
+// NewSparseMatrix creates and returns an n by n sparse matrix from the specified CSR data.
+// It returns an error wrapping ErrShape if the data is inconsistent, or the column indices of a row are not sorted.
+func NewSparseMatrix(n int, rowPtr, colIdx []int, val []float64) (*SparseMatrix, error) {
+
+	m := &SparseMatrix{n: n, RowPtr: rowPtr, ColIdx: colIdx, Val: val}
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// validate verifies that the CSR data of the matrix is consistent and its column indices are sorted.
+func (m *SparseMatrix) validate() error {
+
+	if m.n < 0 || len(m.RowPtr) != m.n+1 || len(m.ColIdx) != len(m.Val) || m.RowPtr[0] != 0 || m.RowPtr[m.n] != len(m.Val) {
+		return fmt.Errorf("%w: sparse matrix data has inconsistent lengths", ErrShape)
+	}
+	for i := 0; i < m.n; i++ {
+		if m.RowPtr[i] > m.RowPtr[i+1] {
+			return fmt.Errorf("%w: sparse matrix row pointers are not sorted", ErrShape)
+		}
+		for e := m.RowPtr[i]; e < m.RowPtr[i+1]; e++ {
+			if j := m.ColIdx[e]; j < 0 || j >= m.n || (e > m.RowPtr[i] && j <= m.ColIdx[e-1]) {
+				return fmt.Errorf("%w: sparse matrix column indices of row %d are not sorted or out of range", ErrShape, i)
+			}
+		}
+	}
+	return nil
+}
+
+// Dims returns the dimensions of the matrix.
+func (m *SparseMatrix) Dims() (r, c int) {
+
+	return m.n, m.n
+}
+
+// At returns the element at row i and column j.
+func (m *SparseMatrix) At(i, j int) float64 {
+
+	if i < 0 || i >= m.n || j < 0 || j >= m.n {
+		panic(mat.ErrIndexOutOfRange)
+	}
+	cols := m.ColIdx[m.RowPtr[i]:m.RowPtr[i+1]]
+	k := sort.SearchInts(cols, j)
+	if k < len(cols) && cols[k] == j {
+		return m.Val[m.RowPtr[i]+k]
+	}
+	return 0
+}
+
+// T returns the transpose of the matrix.
+func (m *SparseMatrix) T() mat.Matrix {
+
+	return mat.Transpose{Matrix: m}
+}
+
+// NNZ returns the number of stored entries.
+func (m *SparseMatrix) NNZ() int {
+
+	return len(m.Val)
+}
+
+// neighborHeap is a max-heap of candidate neighbors ordered by distance.
+type neighborHeap struct {
+	idx  []int
+	dist []float64
+}
+
+func (h *neighborHeap) Len() int           { return len(h.idx) }
+func (h *neighborHeap) Less(i, j int) bool { return h.dist[i] > h.dist[j] }
+func (h *neighborHeap) Swap(i, j int) {
+	h.idx[i], h.idx[j] = h.idx[j], h.idx[i]
+	h.dist[i], h.dist[j] = h.dist[j], h.dist[i]
+}
+func (h *neighborHeap) Push(x interface{}) {} // Unused: elements are added with add
+func (h *neighborHeap) Pop() interface{} {
+	n := len(h.idx) - 1
+	h.idx, h.dist = h.idx[:n], h.dist[:n]
+	return nil
+}
+
+// add offers a candidate neighbor, keeping only the k closest candidates.
+func (h *neighborHeap) add(j int, dist float64, k int) {
+
+	if k <= 0 {
+		return
+	}
+	if len(h.idx) < k {
+		h.idx = append(h.idx, j)
+		h.dist = append(h.dist, dist)
+		heap.Fix(h, len(h.idx)-1)
+	} else if dist < h.dist[0] {
+		h.idx[0], h.dist[0] = j, dist
+		heap.Fix(h, 0)
+	}
+}
+
+// sorted writes the candidates into idx and dist in order of increasing distance.
+func (h *neighborHeap) sorted(idx []int, dist []float64) {
+
+	for i := len(h.idx) - 1; i >= 0; i-- {
+		idx[i], dist[i] = h.idx[0], h.dist[0]
+		heap.Pop(h)
+	}
+}
+
+// nearestNeighbors finds the k nearest neighbors of each of the n points by exhaustive search,
+// given a function returning the distance between two points.
+// It returns the flattened n by k matrices of neighbor indices and distances, each row sorted by increasing distance,
+// or ctx.Err() if ctx is done before finishing.
+func nearestNeighbors(ctx context.Context, n, k int, dist func(i, j int) float64) ([]int, []float64, error) {
+
+	idx := make([]int, n*k)
+	dists := make([]float64, n*k)
+	h := &neighborHeap{idx: make([]int, 0, k), dist: make([]float64, 0, k)}
+	for i := 0; i < n; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		for j := 0; j < n; j++ {
+			if i != j {
+				h.add(j, dist(i, j), k)
+			}
+		}
+		h.sorted(idx[i*k:(i+1)*k], dists[i*k:(i+1)*k])
+	}
+	return idx, dists, nil
+}
+
+// numNeighbors returns the number of nearest neighbors used for sparse affinities.
+func (tsne *TSNE) numNeighbors() int {
+
+	// At least one neighbor, since perplexities below 1/3 would round down to none
+	k := int(3 * tsne.Perplexity)
+	if k < 1 {
+		k = 1
+	}
+	if k > tsne.n-1 {
+		k = tsne.n - 1
+	}
+	return k
+}
+
+// NeighborMethod selects how the nearest neighbors of the datapoints are found for sparse input affinities.
+type NeighborMethod int
+
+const (
+	// NeighborsAuto uses a vantage-point tree if the metric is known to support it, and exhaustive search otherwise.
+	NeighborsAuto NeighborMethod = iota
+	// NeighborsExhaustive compares every pair of datapoints, in O(n²).
+	NeighborsExhaustive
+	// NeighborsVPTree finds the exact neighbors with a vantage-point tree (see VPTree),
+	// which requires a metric satisfying the triangle inequality. Custom metrics are trusted to satisfy it,
+	// while Cosine and Correlation, which do not, are rejected.
+	NeighborsVPTree
+	// NeighborsRPForest finds approximate neighbors among the datapoints sharing a leaf with each datapoint in
+	// a forest of RPTrees random projection trees, whose leaves have at most RPLeafSize datapoints.
+	// It is the fastest method for large high-dimensional datasets, and works best with euclidean-like metrics.
+	NeighborsRPForest
+)
+
+var neighborMethodNames = []string{"auto", "exhaustive", "VP tree", "RP forest"}
+
+// String returns the name of the method.
+func (m NeighborMethod) String() string {
+
+	if m < 0 || int(m) >= len(neighborMethodNames) {
+		return "unknown"
+	}
+	return neighborMethodNames[m]
+}
+
+// neighborMethod returns the neighbor search method to use, resolving NeighborsAuto.
+func (tsne *TSNE) neighborMethod() NeighborMethod {
+
+	if tsne.Neighbors != NeighborsAuto {
+		return tsne.Neighbors
+	}
+	if isTreeMetric(tsne.metric()) {
+		return NeighborsVPTree
+	}
+	return NeighborsExhaustive
+}
+
+// sparseDataNeighbors finds the nearest neighbors of the rows of Xd according to the configured metric,
+// with the configured neighbor search method.
+func (tsne *TSNE) sparseDataNeighbors(ctx context.Context, Xd *mat.Dense) ([]int, []float64, error) {
+
+	metric := tsne.metric()
+	k := tsne.numNeighbors()
+	method := tsne.neighborMethod()
+	tsne.logger().Info("searching nearest neighbors", "method", method, "neighbors", k)
+	switch method {
+	case NeighborsVPTree:
+		return tsne.vpTreeNeighbors(ctx, Xd, k, metric)
+	case NeighborsRPForest:
+		return tsne.rpForestNeighbors(ctx, Xd, k, metric)
+	}
+	return nearestNeighbors(ctx, tsne.n, k, func(i, j int) float64 {
+		return metric.Distance(Xd.RawRowView(i), Xd.RawRowView(j))
+	})
+}
+
+// sparseDistanceNeighbors finds the nearest neighbors of each point according to the (squared) distance matrix D.
+func (tsne *TSNE) sparseDistanceNeighbors(ctx context.Context, D mat.Matrix) ([]int, []float64, error) {
+
+	return nearestNeighbors(ctx, tsne.n, tsne.numNeighbors(), D.At)
+}
+
+// knn2p computes the sparse P matrix based on the (squared) distances from each point to its k nearest neighbors.
+// It performs the same binary search as d2p, but only over the neighbors of each point,
+// and then symmetrizes and normalizes the result into PSparse.
+// It returns ctx.Err() if ctx is done before finishing.
+func (tsne *TSNE) knn2p(ctx context.Context, idx []int, dist []float64, k int, tol, perplexity float64) error {
+
+	Htarget := math.Log(perplexity)
+	condP := make([]float64, tsne.n*k)
+	tsne.beta = make([]float64, tsne.n)
+	tsne.entropy = make([]float64, tsne.n)
+	converged := make([]bool, tsne.n)
+	log := tsne.logger()
+	err := parallelFor(ctx, tsne.n, tsne.workers(), func(w, i int) {
+		// Log progress
+		if i%500 == 0 {
+			log.Info("computing affinities", "point", i, "points", tsne.n)
+		}
+		tsne.beta[i], tsne.entropy[i], converged[i] = calibrateRow(dist[i*k:(i+1)*k], Htarget, tol, condP[i*k:(i+1)*k])
+	})
+	if err != nil {
+		return err
+	}
+	tsne.checkCalibration(converged)
+	tsne.P = nil
+	tsne.PSparse = symmetrizeNeighbors(tsne.n, k, idx, condP)
+	return nil
+}
+
+// checkCalibration records the datapoints whose calibration did not converge, and logs a warning if there are any.
+func (tsne *TSNE) checkCalibration(converged []bool) {
+
+	tsne.unconverged = nil
+	for i, ok := range converged {
+		if !ok {
+			tsne.unconverged = append(tsne.unconverged, i)
+		}
+	}
+	if len(tsne.unconverged) > 0 {
+		tsne.logger().Warn("perplexity calibration did not converge", "unconverged", len(tsne.unconverged), "points", tsne.n)
+	}
+}
+
+// calibrateRow performs a binary search for the precision (beta) of the Gaussian kernel
+// such that the entropy of the conditional distribution over the specified distances equals Htarget.
+// It writes the resulting probabilities into p and returns beta along with their entropy H,
+// and whether H is within tol of Htarget (which it may not be after MaxBinarySearchSteps steps,
+// e.g. for points with many duplicates or far from all others).
+func calibrateRow(dist []float64, Htarget, tol float64, p []float64) (beta, H float64, converged bool) {
+
+	betaMin := math.Inf(-1)
+	betaMax := math.Inf(1)
+	beta = 1         // initial value of precision
+	var used float64 // precision of the probabilities in p
+	for tries := 0; tries < MaxBinarySearchSteps; tries++ {
+		// Compute raw probabilities with beta precision (along with sum of all raw probabilities)
+		used = beta
+		pSum := float64(0)
+		for j, d := range dist {
+			p[j] = math.Exp(-d * beta)
+			pSum += p[j]
+		}
+		// Normalize probabilities and compute entropy H
+		H = 0
+		for j := range p {
+			if pSum == 0 {
+				p[j] = 0
+			} else {
+				p[j] /= pSum
+			}
+			if p[j] > Epsilon {
+				H -= p[j] * math.Log(p[j])
+			}
+		}
+		// Adjust beta to move H closer to Htarget
+		Hdiff := H - Htarget
+		if math.Abs(Hdiff) < tol {
+			return beta, H, true
+		}
+		if Hdiff > 0 {
+			betaMin = beta
+			if betaMax == math.Inf(1) {
+				beta = beta * 2
+			} else {
+				beta = (beta + betaMax) / 2
+			}
+		} else {
+			betaMax = beta
+			if betaMin == math.Inf(-1) {
+				beta = beta / 2
+			} else {
+				beta = (beta + betaMin) / 2
+			}
+		}
+	}
+	return used, H, false
+}
+
+// symmetrizeNeighbors builds the symmetric joint probability matrix P = (P + P')/(2n)
+// from the conditional probabilities of each of the n points over its k nearest neighbors.
+func symmetrizeNeighbors(n, k int, idx []int, condP []float64) *SparseMatrix {
+
+	// Count the entries of each row, including those mirrored from other rows
+	rowPtr := make([]int, n+1)
+	for i := 0; i < n; i++ {
+		for _, j := range idx[i*k : (i+1)*k] {
+			rowPtr[i+1]++
+			rowPtr[j+1]++
+		}
+	}
+	for i := 0; i < n; i++ {
+		rowPtr[i+1] += rowPtr[i]
+	}
+	// Scatter the entries and their mirrors into the rows
+	colIdx := make([]int, rowPtr[n])
+	val := make([]float64, rowPtr[n])
+	next := make([]int, n)
+	copy(next, rowPtr[:n])
+	for i := 0; i < n; i++ {
+		for c, j := range idx[i*k : (i+1)*k] {
+			v := condP[i*k+c] / float64(2*n)
+			colIdx[next[i]], val[next[i]] = j, v
+			next[i]++
+			colIdx[next[j]], val[next[j]] = i, v
+			next[j]++
+		}
+	}
+	return compactRows(n, rowPtr, colIdx, val)
+}
+
+// compactRows builds an n by n sparse matrix from CSR data whose rows may be unsorted and contain duplicate entries,
+// sorting each row by column and merging its duplicate entries (by adding them) in place.
+func compactRows(n int, rowPtr, colIdx []int, val []float64) *SparseMatrix {
+
+	// Sort each row by column and merge duplicate entries
+	nnz := 0
+	start := 0
+	for i := 0; i < n; i++ {
+		end := rowPtr[i+1]
+		row := sparseRow{colIdx[start:end], val[start:end]}
+		sort.Sort(row)
+		rowStart := nnz
+		for e := start; e < end; e++ {
+			if nnz > rowStart && colIdx[nnz-1] == colIdx[e] {
+				val[nnz-1] += val[e]
+			} else {
+				colIdx[nnz], val[nnz] = colIdx[e], val[e]
+				nnz++
+			}
+		}
+		start = end
+		rowPtr[i+1] = nnz
+	}
+	return &SparseMatrix{n: n, RowPtr: rowPtr, ColIdx: colIdx[:nnz:nnz], Val: val[:nnz:nnz]}
+}
+
+// sparseRow sorts the entries of a sparse row by column index.
+type sparseRow struct {
+	col []int
+	val []float64
+}
+
+func (r sparseRow) Len() int           { return len(r.col) }
+func (r sparseRow) Less(i, j int) bool { return r.col[i] < r.col[j] }
+func (r sparseRow) Swap(i, j int) {
+	r.col[i], r.col[j] = r.col[j], r.col[i]
+	r.val[i], r.val[j] = r.val[j], r.val[i]
+}
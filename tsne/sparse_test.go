@@ -0,0 +1,88 @@
+// Copyright (c) 2018 Daniel Augusto Rizzi Salvadori. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found in the LICENSE file.
+
+package tsne
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+)
+
+// TestSparseAffinities verifies that the sparse P matrix equals the dense one
+// when every point is a neighbor of every other point.
+func TestSparseAffinities(t *testing.T) {
+
+	X := randomData(40, 5)
+	dense := NewTSNE(2, 15, 100, 1, false)
+	dense.n, _ = X.Dims()
+	dense.d2p(context.Background(), SquaredDistanceMatrix(X), EntropyTolerance, dense.Perplexity)
+	sparse := NewTSNE(2, 15, 100, 1, false)
+	sparse.Sparse = true
+	sparse.n, _ = X.Dims()
+	idx, dist, _ := sparse.sparseDataNeighbors(context.Background(), X)
+	sparse.knn2p(context.Background(), idx, dist, sparse.numNeighbors(), EntropyTolerance, sparse.Perplexity)
+	if sparse.PSparse.NNZ() != 40*39 {
+		t.Fatalf("sparse P has %d entries, expected %d", sparse.PSparse.NNZ(), 40*39)
+	}
+	for i := 0; i < 40; i++ {
+		for j := 0; j < 40; j++ {
+			if i != j && math.Abs(sparse.PSparse.At(i, j)-dense.P.At(i, j)) > 1e-12 {
+				t.Fatalf("sparse P(%d, %d) = %v, expected %v", i, j, sparse.PSparse.At(i, j), dense.P.At(i, j))
+			}
+		}
+	}
+}
+
+// TestSparseSmallPerplexity verifies that perplexities below 1/3 still search for one neighbor instead of panicking.
+func TestSparseSmallPerplexity(t *testing.T) {
+
+	X := randomData(20, 3)
+	for _, metric := range []Metric{nil, Cosine} {
+		cfg := DefaultConfig()
+		cfg.Perplexity = 0.3
+		cfg.MaxIter = 10
+		cfg.Sparse = true
+		cfg.Metric = metric
+		tsne, _ := New(cfg)
+		if _, err := tsne.TryEmbedData(X, nil); err != nil {
+			t.Fatal(err)
+		}
+		if tsne.numNeighbors() != 1 {
+			t.Errorf("expected one neighbor, got %d", tsne.numNeighbors())
+		}
+		if _, err := tsne.TryEmbedDistances(SquaredDistanceMatrix(X), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestNewSparseMatrix verifies that inconsistent CSR data is reported with ErrShape instead of panicking.
+func TestNewSparseMatrix(t *testing.T) {
+
+	for _, c := range []struct {
+		name   string
+		n      int
+		rowPtr []int
+		colIdx []int
+		val    []float64
+	}{
+		{"negative size", -1, nil, nil, nil},
+		{"short row pointers", 2, []int{0, 1}, []int{1}, []float64{1}},
+		{"missing values", 2, []int{0, 1, 2}, []int{1, 0}, []float64{1}},
+		{"last row pointer", 2, []int{0, 1, 3}, []int{1, 0}, []float64{1, 1}},
+		{"first row pointer", 2, []int{1, 1, 2}, []int{1, 0}, []float64{1, 1}},
+		{"decreasing row pointers", 3, []int{0, 2, 1, 2}, []int{1, 2}, []float64{1, 1}},
+		{"unsorted columns", 2, []int{0, 2, 2}, []int{1, 0}, []float64{1, 1}},
+		{"column out of range", 2, []int{0, 1, 2}, []int{2, 0}, []float64{1, 1}},
+	} {
+		if m, err := NewSparseMatrix(c.n, c.rowPtr, c.colIdx, c.val); !errors.Is(err, ErrShape) || m != nil {
+			t.Errorf("%s: got error %v, expected %v", c.name, err, ErrShape)
+		}
+	}
+	m, err := NewSparseMatrix(2, []int{0, 1, 2}, []int{1, 0}, []float64{3, 4})
+	if err != nil || m.At(0, 1) != 3 || m.At(1, 0) != 4 || m.At(0, 0) != 0 {
+		t.Errorf("unexpected sparse matrix %v (error %v)", m, err)
+	}
+}
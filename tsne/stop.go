@@ -0,0 +1,74 @@
+// Copyright (c) 2018 Daniel Augusto Rizzi Salvadori. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found in the LICENSE file.
+
+package tsne
+
+import (
+	"math"
+)
+
+// StopReason indicates why the optimization stopped.
+type StopReason int
+
+const (
+	StopNone        StopReason = iota // The optimization has not run
+	StopMaxIter                       // MaxIter iterations were performed
+	StopStepFunc                      // The step function requested to stop
+	StopCanceled                      // The context was done
+	StopGradNorm                      // The norm of the gradient fell below MinGradNorm
+	StopImprovement                   // The divergence improved by less than MinRelImprovement over ImprovementWindow iterations
+	StopNoProgress                    // The divergence did not improve for MaxIterWithoutProgress iterations
+)
+
+var stopReasonNames = []string{"none", "max iterations", "step function", "canceled", "gradient norm", "improvement", "no progress"}
+
+// String returns a short description of the reason.
+func (r StopReason) String() string {
+
+	if r < 0 || int(r) >= len(stopReasonNames) {
+		return "unknown"
+	}
+	return stopReasonNames[r]
+}
+
+// StopReason returns the reason why the last optimization (by EmbedData, EmbedDistances, or Resume) stopped.
+func (tsne *TSNE) StopReason() StopReason {
+
+	return tsne.stopReason
+}
+
+// stopRule returns the stopping rule satisfied by the optimization after the last iteration,
+// given the norm of its gradient, or StopNone if the optimization should continue.
+// The rules only consider the iterations after the early exaggeration phase.
+func (tsne *TSNE) stopRule(gradNorm float64) StopReason {
+
+	if tsne.iter <= tsne.ExaggerationIters {
+		return StopNone
+	}
+	if gradNorm < tsne.MinGradNorm {
+		return StopGradNorm
+	}
+	// The divergences of the iterations after the early exaggeration (the history may not start at the first iteration)
+	h := tsne.history
+	if first := len(h) - (tsne.iter - tsne.ExaggerationIters); first > 0 {
+		h = h[first:]
+	}
+	if w := tsne.ImprovementWindow; w > 0 && len(h) > w {
+		prev, cur := h[len(h)-1-w], h[len(h)-1]
+		if prev-cur < tsne.MinRelImprovement*math.Abs(prev) {
+			return StopImprovement
+		}
+	}
+	if m := tsne.MaxIterWithoutProgress; m > 0 && len(h) > m {
+		best := 0
+		for i, v := range h {
+			if v < h[best] {
+				best = i
+			}
+		}
+		if len(h)-1-best >= m {
+			return StopNoProgress
+		}
+	}
+	return StopNone
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2018 Daniel Augusto Rizzi Salvadori. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found in the LICENSE file.
+
+package tsne
+
+import (
+	"context"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// TestStopRule verifies the stopping rules on synthetic divergence histories.
+func TestStopRule(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		cfg      func(*Config)
+		history  []float64
+		gradNorm float64
+		reason   StopReason
+	}{
+		{"disabled", func(cfg *Config) {}, []float64{5, 4, 4, 4, 4, 4}, 0, StopNone},
+		{"exaggeration", func(cfg *Config) { cfg.MinGradNorm = 1; cfg.ExaggerationIters = 6 }, []float64{5, 4, 4, 4, 4, 4}, 0, StopNone},
+		{"gradient norm", func(cfg *Config) { cfg.MinGradNorm = 1 }, []float64{5, 4}, 0.5, StopGradNorm},
+		{"large gradient norm", func(cfg *Config) { cfg.MinGradNorm = 1 }, []float64{5, 4}, 2, StopNone},
+		{"improvement", func(cfg *Config) { cfg.MinRelImprovement = 0.1; cfg.ImprovementWindow = 2 }, []float64{5, 4, 3.9, 3.8}, 1, StopImprovement},
+		{"enough improvement", func(cfg *Config) { cfg.MinRelImprovement = 0.1; cfg.ImprovementWindow = 2 }, []float64{5, 4, 3.5, 3}, 1, StopNone},
+		{"short window", func(cfg *Config) { cfg.MinRelImprovement = 0.1; cfg.ImprovementWindow = 4 }, []float64{5, 4, 3.9, 3.8}, 1, StopNone},
+		{"no progress", func(cfg *Config) { cfg.MaxIterWithoutProgress = 2 }, []float64{5, 4, 4.1, 4.05}, 1, StopNoProgress},
+		{"progress", func(cfg *Config) { cfg.MaxIterWithoutProgress = 2 }, []float64{5, 4, 4.1, 3.9}, 1, StopNone},
+		// Divergences during the early exaggeration are ignored
+		{"no progress after exaggeration", func(cfg *Config) { cfg.MaxIterWithoutProgress = 2; cfg.ExaggerationIters = 2 }, []float64{1, 2, 5, 4, 4.1}, 1, StopNone},
+	}
+	for _, test := range tests {
+		cfg := DefaultConfig()
+		cfg.ExaggerationIters = 0
+		test.cfg(&cfg)
+		tsne, err := New(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tsne.history = test.history
+		tsne.iter = len(test.history)
+		if reason := tsne.stopRule(test.gradNorm); reason != test.reason {
+			t.Errorf("%s: stopped because of %v, expected %v", test.name, reason, test.reason)
+		}
+	}
+}
+
+// TestStopReason verifies the reason reported after embedding.
+func TestStopReason(t *testing.T) {
+
+	X := randomData(40, 3)
+	canceled, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tests := []struct {
+		cfg      func(*Config)
+		ctx      context.Context
+		stepFunc func(iter int, divergence float64, embedding mat.Matrix) bool
+		reason   StopReason
+		iters    int
+	}{
+		{func(cfg *Config) {}, context.Background(), nil, StopMaxIter, 100},
+		{func(cfg *Config) {}, context.Background(), func(iter int, divergence float64, embedding mat.Matrix) bool { return iter == 9 }, StopStepFunc, 10},
+		{func(cfg *Config) { cfg.MinGradNorm = 1e6 }, context.Background(), nil, StopGradNorm, 51},
+		{func(cfg *Config) { cfg.MinRelImprovement = 1; cfg.ImprovementWindow = 5 }, context.Background(), nil, StopImprovement, 56},
+		{func(cfg *Config) {}, canceled, func(iter int, divergence float64, embedding mat.Matrix) bool { cancel(); return false }, StopCanceled, 1},
+	}
+	for _, test := range tests {
+		cfg := DefaultConfig()
+		cfg.Perplexity = 10
+		cfg.MaxIter = 100
+		cfg.ExaggerationIters = 50
+		cfg.Seed = 1
+		test.cfg(&cfg)
+		tsne, _ := New(cfg)
+		tsne.EmbedDataContext(test.ctx, X, test.stepFunc)
+		if tsne.StopReason() != test.reason || tsne.Iteration() != test.iters {
+			t.Errorf("stopped after %d iterations because of %v, expected %d iterations and %v",
+				tsne.Iteration(), tsne.StopReason(), test.iters, test.reason)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright (c) 2018 Daniel Augusto Rizzi Salvadori. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found in the LICENSE file.
+
+package tsne
+
+import (
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Transform embeds new datapoints (the rows of X) into the existing embedding Y, which must have been obtained with EmbedData
+// (or one of its variants). The affinities of each new point to its nearest datapoints of the embedded data are calibrated
+// to the configured perplexity, and then only the positions of the new points are optimized while Y is kept fixed.
+// Since each new point is only attracted to the embedded data, the learning rate is divided by the number of embedded points.
+// The new points are optimized for MaxIter iterations with the configured momentum, without early exaggeration.
+// The step function, if provided, is called with the average divergence and the embedding of the new points.
+// Transform returns the embedding of the new points, or an error wrapping ErrNotEmbedded, ErrShape or ErrNonFinite.
+func (tsne *TSNE) Transform(X mat.Matrix, stepFunc func(iter int, divergence float64, embedding mat.Matrix) bool) (mat.Matrix, error) {
+
+	if tsne.Y == nil || tsne.data == nil {
+		return nil, fmt.Errorf("%w: Transform requires an embedding obtained with EmbedData", ErrNotEmbedded)
+	}
+	if _, d := X.Dims(); d != tsne.data.RawMatrix().Cols {
+		return nil, fmt.Errorf("%w: X has %d columns, expected %d", ErrShape, d, tsne.data.RawMatrix().Cols)
+	}
+	if err := validateData(X); err != nil {
+		return nil, err
+	}
+	Xd := mat.DenseCopyOf(X)
+	m, _ := Xd.Dims()
+	metric := tsne.metric()
+	return tsne.transform(m, func(i, j int) float64 {
+		return metric.Distance(Xd.RawRowView(i), tsne.data.RawRowView(j))
+	}, stepFunc), nil
+}
+
+// TransformDistances is like Transform, but it takes the m by n matrix of (squared, or as given by the configured metric) distances
+// from each of the m new points to each of the n embedded datapoints.
+// It can be used with embeddings obtained with either EmbedData or EmbedDistances. Like TryEmbedDistances,
+// it returns an *ElementError wrapping ErrNonFinite or ErrNegativeDistance for an invalid element of D.
+func (tsne *TSNE) TransformDistances(D mat.Matrix, stepFunc func(iter int, divergence float64, embedding mat.Matrix) bool) (mat.Matrix, error) {
+
+	if tsne.Y == nil {
+		return nil, ErrNotEmbedded
+	}
+	if _, n := D.Dims(); n != tsne.n {
+		return nil, fmt.Errorf("%w: D has %d columns, expected %d", ErrShape, n, tsne.n)
+	}
+	if err := validateNonNegative(D); err != nil {
+		return nil, err
+	}
+	m, _ := D.Dims()
+	return tsne.transform(m, D.At, stepFunc), nil
+}
+
+// transform embeds m new points into the existing embedding, given a function
+// returning the (squared) distance from the i-th new point to the j-th embedded point.
+func (tsne *TSNE) transform(m int, dist func(i, j int) float64, stepFunc func(iter int, divergence float64, embedding mat.Matrix) bool) mat.Matrix {
+
+	// Calibrate the conditional affinities of each new point to its nearest embedded points
+	k := tsne.numNeighbors()
+	perplexity := math.Min(tsne.Perplexity, float64(k))
+	idx := make([]int, m*k)
+	dists := make([]float64, m*k)
+	P := make([]float64, m*k)
+	h := &neighborHeap{idx: make([]int, 0, k), dist: make([]float64, 0, k)}
+	for i := 0; i < m; i++ {
+		for j := 0; j < tsne.n; j++ {
+			h.add(j, dist(i, j), k)
+		}
+		h.sorted(idx[i*k:(i+1)*k], dists[i*k:(i+1)*k])
+		calibrateRow(dists[i*k:(i+1)*k], math.Log(perplexity), EntropyTolerance, P[i*k:(i+1)*k])
+	}
+	// Initialize each new point at the affinity-weighted average of its neighbors
+	d := tsne.DimsOut
+	Y := mat.NewDense(m, d, nil)
+	for i := 0; i < m; i++ {
+		yi := Y.RawRowView(i)
+		for c, j := range idx[i*k : (i+1)*k] {
+			yj := tsne.Y.RawRowView(j)
+			for l := 0; l < d; l++ {
+				yi[l] += P[i*k+c] * yj[l]
+			}
+		}
+	}
+	// Optimize the positions of the new points with momentum and adaptive gains
+	grad := make([]float64, d)
+	velocity := make([]float64, m*d)
+	gains := make([]float64, m*d)
+	for e := range gains {
+		gains[e] = 1
+	}
+	learningRate := tsne.LearningRate / float64(tsne.n)
+	for iter := 0; iter < tsne.MaxIter; iter++ {
+		momentum := tsne.InitialMomentum
+		if iter >= tsne.MomentumSwitchIter {
+			momentum = tsne.FinalMomentum
+		}
+		var divergence float64
+		for i := 0; i < m; i++ {
+			yi := Y.RawRowView(i)
+			divergence += tsne.transformGradient(yi, idx[i*k:(i+1)*k], P[i*k:(i+1)*k], grad)
+			vel, g := velocity[i*d:(i+1)*d], gains[i*d:(i+1)*d]
+			for l := 0; l < d; l++ {
+				if (grad[l] > 0) != (vel[l] > 0) {
+					g[l] += 0.2
+				} else {
+					g[l] *= 0.8
+				}
+				g[l] = math.Max(g[l], tsne.MinGain)
+				vel[l] = momentum*vel[l] - learningRate*g[l]*grad[l]
+				yi[l] += vel[l]
+			}
+		}
+		if stepFunc != nil && stepFunc(iter, divergence/float64(m), Y) {
+			break
+		}
+	}
+	return Y
+}
+
+// transformGradient computes the gradient (into grad) of the KL divergence between the affinities P of a new point
+// at yi to its neighbors idx and its low dimensional affinities to all the embedded points. It returns the divergence.
+func (tsne *TSNE) transformGradient(yi []float64, idx []int, P []float64, grad []float64) float64 {
+
+	// Compute the repulsive forces and the normalization term of Q over all embedded points
+	for l := range grad {
+		grad[l] = 0
+	}
+	tk := tsne.outputKernel()
+	var sumQ float64
+	for j := 0; j < tsne.n; j++ {
+		yj := tsne.Y.RawRowView(j)
+		q, f := tk.at(sqDist(yi, yj))
+		sumQ += q
+		for l := range grad {
+			grad[l] -= q * f * (yi[l] - yj[l])
+		}
+	}
+	for l := range grad {
+		grad[l] /= sumQ
+	}
+	// Add the attractive forces to the neighbors and compute the divergence
+	var divergence float64
+	for c, j := range idx {
+		yj := tsne.Y.RawRowView(j)
+		q, f := tk.at(sqDist(yi, yj))
+		if P[c] > 0 {
+			divergence += P[c] * math.Log(P[c]/math.Max(q/sumQ, GreaterThanZero))
+		}
+		for l := range grad {
+			grad[l] += P[c] * f * (yi[l] - yj[l])
+		}
+	}
+	for l := range grad {
+		grad[l] *= 2
+	}
+	return divergence
+}
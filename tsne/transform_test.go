@@ -0,0 +1,95 @@
+// Copyright (c) 2018 Daniel Augusto Rizzi Salvadori. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found in the LICENSE file.
+
+package tsne
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// TestTransform verifies that new points are embedded next to the cluster they belong to.
+func TestTransform(t *testing.T) {
+
+	// Two well separated clusters
+	X := randomData(80, 5)
+	for i := 40; i < 80; i++ {
+		X.Set(i, 0, X.At(i, 0)+20)
+	}
+	tsne := NewTSNE(2, 10, 200, 300, false)
+	tsne.Seed = 1
+	tsne.EmbedData(X, nil)
+	// New points from each of the clusters
+	Xnew := mat.NewDense(2, 5, nil)
+	Xnew.SetRow(0, X.RawRowView(0))
+	Xnew.SetRow(1, X.RawRowView(79))
+	Xnew.Set(0, 1, Xnew.At(0, 1)+0.1)
+	Xnew.Set(1, 1, Xnew.At(1, 1)+0.1)
+	Ynew, err := tsne.Transform(Xnew, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	centroid := func(from, to int) []float64 {
+		c := make([]float64, 2)
+		for i := from; i < to; i++ {
+			c[0] += tsne.Y.At(i, 0) / float64(to-from)
+			c[1] += tsne.Y.At(i, 1) / float64(to-from)
+		}
+		return c
+	}
+	cA, cB := centroid(0, 40), centroid(40, 80)
+	for i, own := range [][]float64{cA, cB} {
+		other := cB
+		if i == 1 {
+			other = cA
+		}
+		yi := mat.Row(nil, i, Ynew)
+		if sqDist(yi, own) >= sqDist(yi, other) {
+			t.Errorf("new point %d was not embedded next to its cluster", i)
+		}
+	}
+	if _, err := tsne.Transform(mat.NewDense(1, 3, nil), nil); !errors.Is(err, ErrShape) {
+		t.Errorf("got error %v, expected %v", err, ErrShape)
+	}
+	D := mat.NewDense(2, 80, nil)
+	D.Set(1, 5, -1)
+	var elemErr *ElementError
+	if _, err := tsne.TransformDistances(D, nil); !errors.Is(err, ErrNegativeDistance) || !errors.As(err, &elemErr) || elemErr.Row != 1 || elemErr.Col != 5 {
+		t.Errorf("got error %v, expected a negative distance at (1, 5)", err)
+	}
+}
+
+// TestTransformGradient verifies the gradient used by Transform against finite differences,
+// for several degrees of freedom of the kernel, including the kernel of parametric t-SNE in three dimensions.
+func TestTransformGradient(t *testing.T) {
+
+	idx := []int{1, 4, 7}
+	P := []float64{0.5, 0.3, 0.2}
+	for _, kernel := range []struct {
+		dof  float64
+		dims int
+	}{{1, 2}, {0.5, 2}, {2, 2}, {AutoDegreesOfFreedom, 3}} {
+		tsne := NewTSNE(kernel.dims, 5, 100, 0, false)
+		tsne.DegreesOfFreedom = kernel.dof
+		tsne.n = 10
+		tsne.Y = randomData(10, kernel.dims)
+		y := []float64{0.3, -0.2, 0.1}[:kernel.dims]
+		grad := make([]float64, kernel.dims)
+		scratch := make([]float64, kernel.dims)
+		tsne.transformGradient(y, idx, P, grad)
+		for l := range y {
+			orig := y[l]
+			y[l] = orig + 1e-6
+			plus := tsne.transformGradient(y, idx, P, scratch)
+			y[l] = orig - 1e-6
+			minus := tsne.transformGradient(y, idx, P, scratch)
+			y[l] = orig
+			if numerical := (plus - minus) / 2e-6; math.Abs(numerical-grad[l]) > 1e-6 {
+				t.Errorf("dof=%v: gradient %d is %v, expected %v", kernel.dof, l, grad[l], numerical)
+			}
+		}
+	}
+}
@@ -6,10 +6,12 @@
 package tsne
 
 import (
-	"fmt"
+	"context"
 	"math"
 	"math/rand"
+	"time"
 
+	"gonum.org/v1/gonum/floats"
 	"gonum.org/v1/gonum/mat"
 )
 
@@ -22,41 +24,78 @@ const (
 )
 
 // TSNE is a t-Distributed Stochastic Neighbor Embedding (t-SNE) dimensionality reduction object.
+// Its parameters are held in the embedded Config.
 type TSNE struct {
-	n            int     // Number of datapoints
-	dimsOut      int     // Number of dimensions in the low dimensional map
-	perplexity   float64 // Perplexity target for the Gaussian kernels in high dimension
-	learningRate float64 // Gradient descent learning rate
-	verbose      bool    // If true, then TSNE outputs progress data to stdout
-	maxIter      int     // Max number of gradient descent iterations
+	Config
 
-	P *mat.Dense // Matrix of pairwise affinities in the high dimensional space (Gaussian kernel)
-	Q *mat.Dense // Matrix of pairwise affinities in the low dimensional space (t-Student kernel)
-	Y *mat.Dense // The output embedding with dimsOut dimensions
+	n    int             // Number of datapoints
+	iter int             // Number of gradient descent iterations performed so far
+	src  *countingSource // Source of the random number generator (nil if Rand is provided)
+	rng  *rand.Rand      // Random number generator of the current embedding
+	data *mat.Dense      // Embedded data matrix (if embedded with EmbedData), used by Transform
+
+	forestSeeds []int64 // Seeds of the random projection trees used to find the nearest neighbors (if any)
+
+	// Diagnostics of the current embedding, reported by Result
+	history          []float64     // Divergence at each iteration
+	stopReason       StopReason    // Reason why the last optimization stopped
+	beta             []float64     // Precision of the Gaussian kernel of each datapoint
+	entropy          []float64     // Entropy of the conditional distribution of each datapoint
+	unconverged      []int         // Datapoints whose calibration did not reach the target perplexity
+	affinityTime     time.Duration // Time spent computing the input affinities
+	optimizationTime time.Duration // Time spent optimizing the embedding
+
+	Velocity *mat.Dense // Current update step of each element of Y (optimizer state)
+	Gains    *mat.Dense // Current adaptive gain of each element of Y (optimizer state)
+
+	P       *mat.Dense    // Matrix of pairwise affinities in the high dimensional space (Gaussian kernel)
+	PSparse *SparseMatrix // Sparse version of P, used instead of P if Sparse is set
+	Q       *mat.Dense    // Matrix of pairwise affinities in the low dimensional space (t-Student kernel)
+	Y       *mat.Dense    // The output embedding with DimsOut dimensions
 
 	PlogP float64    // The constant portion of the KL divergence, computed only once
 	dCdY  *mat.Dense // Gradient of the KL divergence with respect to the low dimensional map
 }
 
+// New creates and returns a new t-SNE dimensionality reductor with the specified configuration.
+// It returns an error if the configuration is invalid (see Config.Validate).
+func New(cfg Config) (*TSNE, error) {
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &TSNE{Config: cfg}, nil
+}
+
 // NewTSNE creates and returns a new t-SNE dimensionality reductor with the specified parameters.
+// All other parameters have the values returned by DefaultConfig, except that early exaggeration and the initial
+// momentum last for a quarter of maxIter (at most DefaultExaggerationIters and DefaultMomentumSwitchIter iterations),
+// so that most iterations optimize the unexaggerated embedding. The parameters are not validated.
 func NewTSNE(dimensionsOut int, perplexity, learningRate float64, maxIter int, verbose bool) *TSNE {
 
-	tsne := new(TSNE)
-	tsne.dimsOut = dimensionsOut
-	tsne.perplexity = perplexity
-	tsne.learningRate = learningRate
-	tsne.maxIter = maxIter
-	tsne.verbose = verbose
-	return tsne
+	cfg := DefaultConfig()
+	cfg.DimsOut = dimensionsOut
+	cfg.Perplexity = perplexity
+	cfg.LearningRate = learningRate
+	cfg.MaxIter = maxIter
+	cfg.Verbose = verbose
+	if maxIter/4 < cfg.ExaggerationIters {
+		cfg.ExaggerationIters = maxIter / 4
+	}
+	if maxIter/4 < cfg.MomentumSwitchIter {
+		cfg.MomentumSwitchIter = maxIter / 4
+	}
+	return &TSNE{Config: cfg}
 }
 
 // EmbedData initializes the pairwise affinity matrix P with the similarity
 // probabilities calculated based on the provided data matrix and runs t-SNE.
+// The datapoints are compared using the configured Metric (squared euclidean distance by default).
 // It returns the generated embedding.
 func (tsne *TSNE) EmbedData(X mat.Matrix, stepFunc func(iter int, divergence float64, embedding mat.Matrix) bool) mat.Matrix {
 
-	D := SquaredDistanceMatrix(X)
-	return tsne.EmbedDistances(D, stepFunc)
+	tsne.embedData(context.Background(), X, stepFunc)
+	return tsne.Y
 }
 
 // InitDistances initializes the pairwise affinity matrix P with the similarity
@@ -70,26 +109,189 @@ func (tsne *TSNE) EmbedDistances(D mat.Matrix, stepFunc func(iter int, divergenc
 		panic("squared distance matrix is not square")
 	}
 
-	tsne.n = n
-	tsne.d2p(D, EntropyTolerance, tsne.perplexity)
-	tsne.initSolution()
-	tsne.run(stepFunc)
+	tsne.embedDistances(context.Background(), D, stepFunc)
+	return tsne.Y
+}
+
+// TryEmbedData is like EmbedData, but it validates the parameters and the data matrix first.
+// Instead of panicking or producing a meaningless embedding, it returns an error wrapping
+// ErrEmptyInput, ErrTooFewPoints, ErrNonFinite, ErrPerplexity, ErrDimsOut, ErrMaxIter or ErrConfig if they are invalid.
+// Errors concerning a specific element of X are of type *ElementError.
+func (tsne *TSNE) TryEmbedData(X mat.Matrix, stepFunc func(iter int, divergence float64, embedding mat.Matrix) bool) (mat.Matrix, error) {
+
+	return tsne.EmbedDataContext(context.Background(), X, stepFunc)
+}
+
+// TryEmbedDistances is like EmbedDistances, but it validates the parameters and the distance matrix first.
+// Instead of panicking or producing a meaningless embedding, it returns an error wrapping ErrEmptyInput, ErrTooFewPoints,
+// ErrNotSquare, ErrNonFinite, ErrNegativeDistance, ErrPerplexity, ErrDimsOut, ErrMaxIter or ErrConfig if they are invalid.
+// Errors concerning a specific element of D are of type *ElementError.
+func (tsne *TSNE) TryEmbedDistances(D mat.Matrix, stepFunc func(iter int, divergence float64, embedding mat.Matrix) bool) (mat.Matrix, error) {
+
+	return tsne.EmbedDistancesContext(context.Background(), D, stepFunc)
+}
+
+// EmbedDataContext is like TryEmbedData, but it stops as soon as ctx is done, both while computing
+// the input affinities and during the optimization. In that case it returns ctx.Err() along with
+// the embedding optimized so far, which is nil if the optimization had not started.
+func (tsne *TSNE) EmbedDataContext(ctx context.Context, X mat.Matrix, stepFunc func(iter int, divergence float64, embedding mat.Matrix) bool) (mat.Matrix, error) {
+
+	if err := validateData(X); err != nil {
+		return nil, err
+	}
+	n, _ := X.Dims()
+	if err := tsne.validateParams(n); err != nil {
+		return nil, err
+	}
+	tsne.Y = nil
+	err := tsne.embedData(ctx, X, stepFunc)
+	return tsne.embedding(), err
+}
+
+// EmbedDistancesContext is like TryEmbedDistances, but it stops as soon as ctx is done, both while computing
+// the input affinities and during the optimization. In that case it returns ctx.Err() along with
+// the embedding optimized so far, which is nil if the optimization had not started.
+func (tsne *TSNE) EmbedDistancesContext(ctx context.Context, D mat.Matrix, stepFunc func(iter int, divergence float64, embedding mat.Matrix) bool) (mat.Matrix, error) {
+
+	if err := validateDistances(D); err != nil {
+		return nil, err
+	}
+	n, _ := D.Dims()
+	if err := tsne.validateParams(n); err != nil {
+		return nil, err
+	}
+	tsne.Y = nil
+	err := tsne.embedDistances(ctx, D, stepFunc)
+	return tsne.embedding(), err
+}
+
+// Iteration returns the number of gradient descent iterations performed so far in the current embedding.
+func (tsne *TSNE) Iteration() int {
+
+	return tsne.iter
+}
+
+// embedding returns Y as a mat.Matrix, or nil if it has not been initialized.
+func (tsne *TSNE) embedding() mat.Matrix {
+
+	if tsne.Y == nil {
+		return nil
+	}
 	return tsne.Y
 }
 
-// initSolution initializes the t-SNE solution.
-func (tsne *TSNE) initSolution() {
+// embedData computes the input affinities from the data matrix X (compared with the configured metric) and runs t-SNE.
+// It returns ctx.Err() if ctx is done before finishing.
+func (tsne *TSNE) embedData(ctx context.Context, X mat.Matrix, stepFunc func(iter int, divergence float64, embedding mat.Matrix) bool) error {
+
+	tsne.n, _ = X.Dims()
+	tsne.data = mat.DenseCopyOf(X)
+	tsne.initRand()
+	start := time.Now()
+	if tsne.Sparse {
+		idx, dist, err := tsne.sparseDataNeighbors(ctx, tsne.data)
+		if err != nil {
+			return err
+		}
+		if err := tsne.knn2p(ctx, idx, dist, tsne.numNeighbors(), EntropyTolerance, tsne.Perplexity); err != nil {
+			return err
+		}
+	} else {
+		var D mat.Matrix
+		if tsne.Metric == nil {
+			D = SquaredDistanceMatrix(tsne.data)
+		} else {
+			D = DistanceMatrix(tsne.data, tsne.Metric)
+		}
+		if err := tsne.d2p(ctx, D, EntropyTolerance, tsne.Perplexity); err != nil {
+			return err
+		}
+	}
+	tsne.affinityTime = time.Since(start)
+	tsne.initSolution(tsne.data, nil)
+	return tsne.run(ctx, stepFunc)
+}
+
+// embedDistances computes the input affinities from the (squared) distance matrix D and runs t-SNE.
+// It returns ctx.Err() if ctx is done before finishing.
+func (tsne *TSNE) embedDistances(ctx context.Context, D mat.Matrix, stepFunc func(iter int, divergence float64, embedding mat.Matrix) bool) error {
+
+	tsne.n, _ = D.Dims()
+	tsne.data = nil
+	tsne.initRand()
+	start := time.Now()
+	if tsne.Sparse {
+		idx, dist, err := tsne.sparseDistanceNeighbors(ctx, D)
+		if err != nil {
+			return err
+		}
+		if err := tsne.knn2p(ctx, idx, dist, tsne.numNeighbors(), EntropyTolerance, tsne.Perplexity); err != nil {
+			return err
+		}
+	} else {
+		if err := tsne.d2p(ctx, D, EntropyTolerance, tsne.Perplexity); err != nil {
+			return err
+		}
+	}
+	tsne.affinityTime = time.Since(start)
+	tsne.initSolution(nil, D)
+	return tsne.run(ctx, stepFunc)
+}
+
+// initSolution initializes the t-SNE solution, using the random number generator initialized by initRand.
+// X is the data matrix and D the (squared) distance matrix, one of which is used for the initial embedding if Init is InitPCA.
+func (tsne *TSNE) initSolution(X, D mat.Matrix) {
 
-	// Allocate the embedding matrix (result)
-	tsne.Y = mat.NewDense(tsne.n, tsne.dimsOut, nil)
-	tsne.Y.Apply(func(i, j int, v float64) float64 {
-		return RandNormal(0, InitialStandardDeviation)
-	}, tsne.Y)
+	// Initialize the embedding matrix (result)
+	tsne.Y = tsne.initialEmbedding(X, D)
 
 	// Allocate gradient matrix
-	tsne.dCdY = mat.NewDense(tsne.n, tsne.dimsOut, nil)
+	tsne.dCdY = mat.NewDense(tsne.n, tsne.DimsOut, nil)
+
+	// Initialize the optimizer state
+	tsne.iter = 0
+	tsne.history = nil
+	tsne.stopReason = StopNone
+	tsne.optimizationTime = 0
+	tsne.Velocity = mat.NewDense(tsne.n, tsne.DimsOut, nil)
+	tsne.Gains = mat.NewDense(tsne.n, tsne.DimsOut, nil)
+	tsne.Gains.Apply(func(i, j int, v float64) float64 {
+		return 1
+	}, tsne.Gains)
 
 	// Compute and store the constant portion of the KL divergence
+	tsne.computePlogP()
+}
+
+// initRand initializes the random number generator of the embedding, from Rand if provided or else from Seed.
+// If Seed is zero, it is first set from the current time. It is called before computing the input affinities,
+// which may also draw from it.
+func (tsne *TSNE) initRand() {
+
+	tsne.forestSeeds = nil
+	if tsne.Rand != nil {
+		tsne.src, tsne.rng = nil, tsne.Rand
+		return
+	}
+	if tsne.Seed == 0 {
+		tsne.Seed = time.Now().UnixNano()
+	}
+	tsne.src = newCountingSource(tsne.Seed)
+	tsne.rng = rand.New(tsne.src)
+}
+
+// computePlogP computes and stores the constant portion of the KL divergence.
+func (tsne *TSNE) computePlogP() {
+
+	if tsne.PSparse != nil {
+		tsne.PlogP = 0
+		for _, p := range tsne.PSparse.Val {
+			if p > 0 {
+				tsne.PlogP += p * math.Log(p)
+			}
+		}
+		return
+	}
 	PlogP := mat.NewDense(tsne.n, tsne.n, nil)
 	PlogP.CloneFrom(tsne.P)
 	PlogP.Apply(func(i, j int, v float64) float64 {
@@ -103,175 +305,209 @@ func (tsne *TSNE) initSolution() {
 // It performs a binary search to obtain a similarity probability for each pairwise distance
 // in such a way that each Gaussian kernel has the same perplexity (specified).
 // D should be a squared distance matrix, it should be square and symmetric.
-func (tsne *TSNE) d2p(D mat.Matrix, tol, perplexity float64) {
+// The rows are calibrated in parallel by the configured number of workers.
+// It returns ctx.Err() if ctx is done before finishing.
+func (tsne *TSNE) d2p(ctx context.Context, D mat.Matrix, tol, perplexity float64) error {
 
 	// The target entropy of the gaussian kernels is the log of the target perplexity
 	Htarget := math.Log(perplexity)
 
 	// Allocate the probability matrix
 	tsne.P = mat.NewDense(tsne.n, tsne.n, nil)
+	tsne.PSparse = nil
+	tsne.beta = make([]float64, tsne.n)
+	tsne.entropy = make([]float64, tsne.n)
+	converged := make([]bool, tsne.n)
 
+	// Calibrate the rows in parallel, each worker using its own buffers for the distances and
+	// probabilities to the other points (excluding the point itself, whose probability is zero)
 	dDense := mat.DenseCopyOf(D)
-	// Loop over all data points
-	for i := 0; i < tsne.n; i++ {
-		// Print progress
-		if tsne.verbose && i%500 == 0 {
-			fmt.Printf("Computing P-values for point %d of %d...\n", i, tsne.n)
-		}
-		// Perform a binary search for the Gaussian kernel precision (beta)
-		// such that the entropy (and thus the perplexity) of the distribution
-		// given the data is the same for all data points
-		betaMin := math.Inf(-1)
-		betaMax := math.Inf(1)
-		beta := float64(1) // initial value of precision
-		Di := dDense.RowView(i)
-		for tries := 0; tries < MaxBinarySearchSteps; tries++ {
-			// Compute raw probabilities with beta precision (along with sum of all raw probabilities)
-			pSum := float64(0)
-			for j := 0; j < Di.Len(); j++ {
-				var p float64
-				if i == j {
-					p = 0
-				} else {
-					p = math.Exp(-Di.AtVec(j) * beta)
-				}
-				tsne.P.Set(i, j, p)
-				pSum += p
-			}
-			// Normalize probabilities and compute entropy H
-			var H float64 // Distribution entropy
-			for j := 0; j < Di.Len(); j++ {
-				var p float64
-				if pSum == 0 {
-					p = 0
-				} else {
-					p = tsne.P.At(i, j) / pSum
-				}
-				tsne.P.Set(i, j, p)
-				if p > Epsilon {
-					H -= p * math.Log(p)
-				}
-			}
-			// Adjust beta to move H closer to Htarget
-			Hdiff := H - Htarget
-			if Hdiff > 0 {
-				// Entropy is too high (distribution too spread-out)
-				// So we need to increase the precision
-				betaMin = beta // Move up the bounds
-				if betaMax == math.Inf(1) {
-					beta = beta * 2
-				} else {
-					beta = (beta + betaMax) / 2
-				}
-			} else {
-				// Entropy is too low - need to decrease precision
-				betaMax = beta // Move down the bounds
-				if betaMin == math.Inf(-1) {
-					beta = beta / 2
-				} else {
-					beta = (beta + betaMin) / 2
-				}
-			}
-			// If current entropy is within specified tolerance - we are done with this data point
-			if math.Abs(Hdiff) < tol {
-				break
-			}
+	workers := tsne.workers()
+	dist := make([][]float64, workers)
+	p := make([][]float64, workers)
+	for w := range dist {
+		dist[w] = make([]float64, tsne.n-1)
+		p[w] = make([]float64, tsne.n-1)
+	}
+	log := tsne.logger()
+	err := parallelFor(ctx, tsne.n, workers, func(w, i int) {
+		// Log progress
+		if i%500 == 0 {
+			log.Info("computing affinities", "point", i, "points", tsne.n)
 		}
+		Di := dDense.RawRowView(i)
+		copy(dist[w][:i], Di[:i])
+		copy(dist[w][i:], Di[i+1:])
+		tsne.beta[i], tsne.entropy[i], converged[i] = calibrateRow(dist[w], Htarget, tol, p[w])
+		Pi := tsne.P.RawRowView(i)
+		copy(Pi[:i], p[w][:i])
+		copy(Pi[i+1:], p[w][i:])
+	})
+	if err != nil {
+		return err
 	}
+	tsne.checkCalibration(converged)
 	// Symmetrize and normalize P
 	tsne.P.Add(tsne.P, tsne.P.T())
 	tsne.P.Scale(1/float64(2*tsne.n), tsne.P)
 	tsne.P.Apply(func(i, j int, v float64) float64 {
 		return math.Max(v, GreaterThanZero)
 	}, tsne.P)
+	return nil
 }
 
-// run performs batch gradient descent to reduce the Kullback-Leibler divergence between P and Q,
+// run performs batch gradient descent with momentum and adaptive gains to reduce the Kullback-Leibler divergence between P and Q,
 // the high dimensional affinities and the low dimensional affinities respectively.
-func (tsne *TSNE) run(stepFunc func(iter int, divergence float64, embedding mat.Matrix) bool) {
-
-	for iter := 0; iter < tsne.maxIter; iter++ {
+// It runs until MaxIter iterations have been performed, the step function returns true, or a stopping rule is satisfied.
+// It returns ctx.Err() if ctx is done before finishing, leaving Y as optimized so far.
+func (tsne *TSNE) run(ctx context.Context, stepFunc func(iter int, divergence float64, embedding mat.Matrix) bool) error {
+
+	log := tsne.logger()
+	log.Info("optimizing embedding", "points", tsne.n, "method", tsne.method(), "iteration", tsne.iter, "maxIter", tsne.MaxIter)
+	start := time.Now()
+	defer func() {
+		tsne.optimizationTime += time.Since(start)
+		log.Info("optimization stopped", "reason", tsne.stopReason, "iterations", tsne.iter, "elapsed", time.Since(start))
+	}()
+	tsne.stopReason = StopMaxIter
+	for tsne.iter < tsne.MaxIter {
+		if err := ctx.Err(); err != nil {
+			tsne.stopReason = StopCanceled
+			return err
+		}
+		iter := tsne.iter
+		// Exaggerate P during the early iterations
+		exaggeration := float64(1)
+		if iter < tsne.ExaggerationIters {
+			exaggeration = tsne.Exaggeration
+		}
 		// Compute KL divergence and update the gradient matrix
-		divergence := tsne.costGradient(tsne.P, tsne.Y)
-		// Step in the direction of negative gradient (times the learning rate)
-		scaledGrad := mat.NewDense(tsne.n, tsne.dimsOut, nil)
-		scaledGrad.CloneFrom(tsne.dCdY)
-		scaledGrad.Scale(tsne.learningRate, scaledGrad)
-		tsne.Y.Sub(tsne.Y, scaledGrad)
+		divergence := tsne.gradient(exaggeration)
+		tsne.history = append(tsne.history, divergence)
+		gradNorm := mat.Norm(tsne.dCdY, 2)
+		log.Debug("iteration", "iteration", iter, "divergence", divergence, "gradientNorm", gradNorm)
+		if iter == tsne.ExaggerationIters && iter > 0 {
+			log.Info("early exaggeration ended", "iteration", iter, "divergence", divergence)
+		}
+		// Step in the direction of negative gradient (times the learning rate and gains), with momentum
+		momentum := tsne.InitialMomentum
+		if iter >= tsne.MomentumSwitchIter {
+			momentum = tsne.FinalMomentum
+		}
+		if iter == tsne.MomentumSwitchIter && iter > 0 {
+			log.Info("momentum switched", "iteration", iter, "momentum", momentum)
+		}
+		tsne.step(momentum)
 		// Reproject Y to have zero mean
-		ymean := make([]float64, tsne.dimsOut)
+		ymean := make([]float64, tsne.DimsOut)
 		for i := 0; i < tsne.n; i++ {
-			for d := 0; d < tsne.dimsOut; d++ {
+			for d := 0; d < tsne.DimsOut; d++ {
 				ymean[d] += tsne.Y.At(i, d)
 			}
 		}
 		tsne.Y.Apply(func(i, j int, v float64) float64 {
 			return v - ymean[j]/float64(tsne.n)
 		}, tsne.Y)
+		tsne.iter++
 		// If provided, call user step function
 		if stepFunc != nil {
 			stop := stepFunc(iter, divergence, tsne.Y)
 			if stop {
+				tsne.stopReason = StopStepFunc
 				break
 			}
 		}
+		// Stop if any of the configured stopping rules is satisfied
+		if reason := tsne.stopRule(gradNorm); reason != StopNone {
+			tsne.stopReason = reason
+			break
+		}
+	}
+	return nil
+}
+
+// gradient computes the KL divergence between P and Q and stores its gradient with respect to Y in dCdY,
+// using the exact computation or the Barnes-Hut approximation as configured.
+func (tsne *TSNE) gradient(exaggeration float64) float64 {
+
+	if tsne.method() != MethodExact || tsne.PSparse != nil {
+		return tsne.splitCostGradient(tsne.Y, exaggeration)
+	}
+	return tsne.costGradient(tsne.P, tsne.Y, exaggeration)
+}
+
+// step updates the gains and velocity based on the current gradient and moves Y by the velocity.
+// Gains increase for elements whose gradient sign opposes the direction of the previous step, and decrease otherwise.
+func (tsne *TSNE) step(momentum float64) {
+
+	for i := 0; i < tsne.n; i++ {
+		grad := tsne.dCdY.RawRowView(i)
+		gains := tsne.Gains.RawRowView(i)
+		vel := tsne.Velocity.RawRowView(i)
+		y := tsne.Y.RawRowView(i)
+		for k := range grad {
+			if (grad[k] > 0) != (vel[k] > 0) {
+				gains[k] += 0.2
+			} else {
+				gains[k] *= 0.8
+			}
+			gains[k] = math.Max(gains[k], tsne.MinGain)
+			vel[k] = momentum*vel[k] - tsne.LearningRate*gains[k]*grad[k]
+			y[k] += vel[k]
+		}
 	}
 }
 
 // costGradient computes the Kullback-Leibler divergence between
-// P and the Student-t based joint probability distribution Q.
+// P and the Student-t based joint probability distribution Q (with the configured degrees of freedom).
 // It also computes the gradient of the divergence with respect to the
 // low-dimensional map Y (the desired output of t-SNE).
-func (tsne *TSNE) costGradient(P, Y mat.Matrix) float64 {
-
-	// Initialize divergence and gradient matrix
-	var divergence float64
-	n, d := Y.Dims()
-	// Compute Q matrix of low dimensional affinities (unnormalized at first)
-	Qu := mat.DenseCopyOf(SquaredDistanceMatrix(Y))
-	Qu.Apply(func(i, j int, v float64) float64 {
-		if i == j { // Clear diagonal
-			return 0
-		} else { // Student t-distribution
-			return 1 / (1 + v)
+// The gradient is computed with P multiplied by the specified exaggeration factor,
+// while the divergence is always computed with P itself.
+// The rows are processed in parallel, and their partial sums are reduced in row order
+// so that the result does not depend on the number of workers.
+func (tsne *TSNE) costGradient(P, Y *mat.Dense, exaggeration float64) float64 {
+
+	n, _ := Y.Dims()
+	workers := tsne.workers()
+	tk := tsne.outputKernel()
+	// Compute the normalization term of Q (Student t-distribution)
+	rowSum := make([]float64, n)
+	parallelFor(context.Background(), n, workers, func(w, i int) {
+		yi := Y.RawRowView(i)
+		for j := 0; j < n; j++ {
+			if i != j {
+				qu, _ := tk.at(sqDist(yi, Y.RawRowView(j)))
+				rowSum[i] += qu
+			}
 		}
-	}, Qu)
-	// Normalize Q matrix
-	sumQu := mat.Sum(Qu)
-	Q := mat.NewDense(n, n, nil)
-	Q.CloneFrom(Qu)
-	Q.Scale(1/sumQu, Q)
-	Q.Apply(func(i, j int, v float64) float64 {
-		return math.Max(v, GreaterThanZero)
-	}, Q)
-	// Compute the the non-constant portion of the divergence
-	logQ := mat.NewDense(n, n, nil)
-	logQ.CloneFrom(Q)
-	logQ.Apply(func(i, j int, v float64) float64 {
-		return math.Log(v)
-	}, logQ)
-	PlogQmat := mat.NewDense(n, n, nil)
-	PlogQmat.MulElem(P, logQ)
-	// Compute the divergence
-	PlogQ := mat.Sum(PlogQmat)
-	divergence = tsne.PlogP - PlogQ
-	// Compute the matrix of scalar multiples for scaling the 3D difference matrix prior to squashing
-	mult := mat.NewDense(n, n, nil)
-	mult.Sub(P, Q)
-	mult.Scale(4, mult)
-	mult.MulElem(mult, Qu)
-	// Compute the gradient
-	for r := 0; r < n; r++ {
-		for c := 0; c < n; c++ {
-			m := mult.At(r, c)
-			for k := 0; k < d; k++ {
-				yDiff := Y.At(r, k) - Y.At(c, k)
-				orig := tsne.dCdY.At(r, k)
-				tsne.dCdY.Set(r, k, orig+m*yDiff)
+	})
+	sumQu := floats.Sum(rowSum)
+	// Compute the gradient and the non-constant portion of the divergence of each row
+	parallelFor(context.Background(), n, workers, func(w, i int) {
+		yi := Y.RawRowView(i)
+		Pi := P.RawRowView(i)
+		grad := tsne.dCdY.RawRowView(i)
+		for k := range grad {
+			grad[k] = 0
+		}
+		var PlogQ float64
+		for j := 0; j < n; j++ {
+			yj := Y.RawRowView(j)
+			var qu, f float64
+			if i != j {
+				qu, f = tk.at(sqDist(yi, yj))
+			}
+			q := math.Max(qu/sumQu, GreaterThanZero)
+			PlogQ += Pi[j] * math.Log(q)
+			mult := 4 * (exaggeration*Pi[j] - q) * f
+			for k := range grad {
+				grad[k] += mult * (yi[k] - yj[k])
 			}
 		}
-	}
-	return divergence
+		rowSum[i] = PlogQ
+	})
+	return tsne.PlogP - floats.Sum(rowSum)
 }
 
 //
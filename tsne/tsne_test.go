@@ -4,6 +4,8 @@
 package tsne
 
 import (
+	"context"
+	"errors"
 	"testing"
 	"gonum.org/v1/gonum/mat"
 )
@@ -25,3 +27,73 @@ func TestSquaredDistanceMatrix(t *testing.T) {
 		}
 	}
 }
+
+// TestStep verifies the momentum and adaptive gains update of the optimizer.
+func TestStep(t *testing.T) {
+
+	tsne := NewTSNE(1, 1, 10, 1, false)
+	tsne.n = 2
+	tsne.Y = mat.NewDense(2, 1, []float64{0, 0})
+	tsne.dCdY = mat.NewDense(2, 1, []float64{-1, 1})
+	tsne.Velocity = mat.NewDense(2, 1, []float64{1, 1})
+	tsne.Gains = mat.NewDense(2, 1, []float64{1, 0.01})
+	tsne.step(0.5)
+	// The first descent direction agrees with the previous step so its gain increases,
+	// the second one opposes it so its gain decreases (down to MinGain)
+	expectedGains := []float64{1.2, DefaultMinGain}
+	expectedVel := []float64{0.5 + 10*1.2, 0.5 - 10*DefaultMinGain}
+	for i := 0; i < 2; i++ {
+		if tsne.Gains.At(i, 0) != expectedGains[i] {
+			t.Errorf("gain %d is %v, expected %v", i, tsne.Gains.At(i, 0), expectedGains[i])
+		}
+		if tsne.Velocity.At(i, 0) != expectedVel[i] || tsne.Y.At(i, 0) != expectedVel[i] {
+			t.Errorf("velocity %d is %v, expected %v", i, tsne.Velocity.At(i, 0), expectedVel[i])
+		}
+	}
+}
+
+// TestEmbedDataContext verifies that embeddings stop when their context is canceled.
+func TestEmbedDataContext(t *testing.T) {
+
+	X := randomData(20, 3)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	Y, err := NewTSNE(2, 5, 100, 10, false).EmbedDataContext(ctx, X, nil)
+	if !errors.Is(err, context.Canceled) || Y != nil {
+		t.Errorf("got %v and %v, expected a nil embedding and context.Canceled", Y, err)
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	lastIter := -1
+	Y, err = NewTSNE(2, 5, 100, 10, false).EmbedDataContext(ctx, X, func(iter int, divergence float64, embedding mat.Matrix) bool {
+		lastIter = iter
+		if iter == 4 {
+			cancel()
+		}
+		return false
+	})
+	if !errors.Is(err, context.Canceled) || Y == nil || lastIter != 4 {
+		t.Errorf("got error %v after iteration %d, expected a partial embedding and context.Canceled after iteration 4", err, lastIter)
+	}
+}
+
+// TestSeed verifies that embeddings with the same seed are identical.
+func TestSeed(t *testing.T) {
+
+	X := randomData(30, 4)
+	embed := func(seed int64) mat.Matrix {
+		tsne := NewTSNE(2, 5, 100, 20, false)
+		tsne.Seed = seed
+		return tsne.EmbedData(X, nil)
+	}
+	if !mat.Equal(embed(42), embed(42)) {
+		t.Error("embeddings with the same seed differ")
+	}
+	if mat.Equal(embed(42), embed(43)) {
+		t.Error("embeddings with different seeds are identical")
+	}
+	tsne := NewTSNE(2, 5, 100, 1, false)
+	tsne.EmbedData(X, nil)
+	if tsne.Seed == 0 {
+		t.Error("seed was not stored")
+	}
+}
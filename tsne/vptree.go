@@ -0,0 +1,221 @@
+// Copyright (c) 2018 Daniel Augusto Rizzi Salvadori. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found in the LICENSE file.
+
+package tsne
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sort"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// VPTree is a vantage-point tree over the rows of a data matrix, which answers exact nearest neighbor
+// and radius queries without comparing the query against every row.
+// Each node holds a vantage point and splits the remaining rows of its subtree into those closer to it than
+// the median distance and those farther away, and queries use the triangle inequality to skip subtrees.
+// A VPTree is safe for concurrent queries.
+type VPTree struct {
+	data    *mat.Dense
+	metric  Metric    // Metric used to traverse the tree
+	squared bool      // Whether the distances are the squares of those of metric (for SquaredEuclidean)
+	index   []int     // Rows of data in tree order: the subtree over [lo, hi) has its vantage point at lo
+	radius  []float64 // Median distance from the vantage point at each position to the rest of its subtree
+}
+
+// NewVPTree builds a vantage-point tree over the rows of X for the specified metric, which must satisfy the
+// triangle inequality, such as Euclidean, Manhattan, Chebyshev, Hamming or a custom metric that does (otherwise
+// queries may miss some rows). SquaredEuclidean, which does not, is also supported (and used if metric is nil)
+// by traversing the tree with Euclidean distances.
+// The tree is built deterministically from X.
+func NewVPTree(X mat.Matrix, metric Metric) *VPTree {
+
+	n, _ := X.Dims()
+	t := &VPTree{
+		data:   mat.DenseCopyOf(X),
+		metric: metric,
+		index:  make([]int, n),
+		radius: make([]float64, n),
+	}
+	if metric == nil || metric == SquaredEuclidean {
+		t.metric, t.squared = Euclidean, true
+	}
+	for i := range t.index {
+		t.index[i] = i
+	}
+	t.build(0, n, make([]float64, n), rand.New(rand.NewSource(1)))
+	return t
+}
+
+// build arranges the rows in index[lo:hi] into a subtree, using dist as scratch space.
+func (t *VPTree) build(lo, hi int, dist []float64, rnd *rand.Rand) {
+
+	if hi-lo < 2 {
+		return
+	}
+	// Pick a random vantage point and sort the other rows by their distance to it
+	v := lo + rnd.Intn(hi-lo)
+	t.index[lo], t.index[v] = t.index[v], t.index[lo]
+	vp := t.row(t.index[lo])
+	for e := lo + 1; e < hi; e++ {
+		dist[e] = t.metric.Distance(vp, t.row(t.index[e]))
+	}
+	sort.Sort(byDistance{t.index[lo+1 : hi], dist[lo+1 : hi]})
+	// The rows before mid are at most the median distance away, and those from mid on are at least that far
+	mid := (lo + 1 + hi) / 2
+	t.radius[lo] = dist[mid]
+	t.build(lo+1, mid, dist, rnd)
+	t.build(mid, hi, dist, rnd)
+}
+
+// row returns the i-th row of the data.
+func (t *VPTree) row(i int) []float64 {
+
+	return t.data.RawRowView(i)
+}
+
+// Len returns the number of rows in the tree.
+func (t *VPTree) Len() int {
+
+	return len(t.index)
+}
+
+// KNearest returns the indices of the k rows nearest to q and their distances to q, in order of increasing distance.
+// If the tree has less than k rows, all of them are returned.
+func (t *VPTree) KNearest(q []float64, k int) ([]int, []float64) {
+
+	return t.kNearest(q, k, -1, &neighborHeap{})
+}
+
+// kNearest returns the k rows nearest to q other than the row exclude, using h (which must be empty) for the candidates.
+func (t *VPTree) kNearest(q []float64, k, exclude int, h *neighborHeap) ([]int, []float64) {
+
+	if k <= 0 {
+		return nil, nil
+	}
+	t.search(0, len(t.index), q, func(i int, dist float64) {
+		if i != exclude {
+			h.add(i, dist, k)
+		}
+	}, func() float64 {
+		if len(h.idx) < k {
+			return math.Inf(1)
+		}
+		return h.dist[0]
+	})
+	idx := make([]int, len(h.idx))
+	dist := make([]float64, len(h.idx))
+	h.sorted(idx, dist)
+	if t.squared {
+		for c, i := range idx {
+			dist[c] = sqDist(q, t.row(i))
+		}
+	}
+	return idx, dist
+}
+
+// Radius returns the indices of the rows at most r away from q and their distances to q, in order of increasing distance.
+func (t *VPTree) Radius(q []float64, r float64) ([]int, []float64) {
+
+	tau := r
+	if t.squared {
+		tau = math.Sqrt(r)
+	}
+	var found byDistance
+	t.search(0, len(t.index), q, func(i int, dist float64) {
+		if dist <= tau {
+			if t.squared {
+				dist = sqDist(q, t.row(i))
+			}
+			if dist <= r {
+				found.idx = append(found.idx, i)
+				found.dist = append(found.dist, dist)
+			}
+		}
+	}, func() float64 {
+		return tau
+	})
+	sort.Sort(found)
+	return found.idx, found.dist
+}
+
+// search visits the subtree over index[lo:hi], calling visit with each row that may be within the search radius
+// of q and its distance to q. The subtrees that cannot contain rows within the search radius (as returned by tau) are skipped.
+func (t *VPTree) search(lo, hi int, q []float64, visit func(i int, dist float64), tau func() float64) {
+
+	if lo >= hi {
+		return
+	}
+	dist := t.metric.Distance(q, t.row(t.index[lo]))
+	visit(t.index[lo], dist)
+	if hi-lo < 2 {
+		return
+	}
+	// Search the side of the median distance containing q first, since it is more likely to shrink the radius
+	// The bounds allow for rounding errors in the distances, so that no subtree is wrongly skipped
+	mid := (lo + 1 + hi) / 2
+	r := t.radius[lo]
+	slack := 1e-12 * (dist + r)
+	if dist < r {
+		if dist-tau() <= r+slack {
+			t.search(lo+1, mid, q, visit, tau)
+		}
+		if dist+tau() >= r-slack {
+			t.search(mid, hi, q, visit, tau)
+		}
+	} else {
+		if dist+tau() >= r-slack {
+			t.search(mid, hi, q, visit, tau)
+		}
+		if dist-tau() <= r+slack {
+			t.search(lo+1, mid, q, visit, tau)
+		}
+	}
+}
+
+// byDistance sorts neighbors by increasing distance.
+type byDistance struct {
+	idx  []int
+	dist []float64
+}
+
+func (b byDistance) Len() int           { return len(b.idx) }
+func (b byDistance) Less(i, j int) bool { return b.dist[i] < b.dist[j] }
+func (b byDistance) Swap(i, j int) {
+	b.idx[i], b.idx[j] = b.idx[j], b.idx[i]
+	b.dist[i], b.dist[j] = b.dist[j], b.dist[i]
+}
+
+// vpTreeNeighbors finds the k nearest neighbors of each of the rows of X according to the metric by querying a
+// vantage-point tree, in parallel if the metric allows it (see metricWorkers). It returns the same flattened matrices
+// as nearestNeighbors, or ctx.Err() if ctx is done before finishing.
+func (tsne *TSNE) vpTreeNeighbors(ctx context.Context, X *mat.Dense, k int, metric Metric) ([]int, []float64, error) {
+
+	n, _ := X.Dims()
+	tree := NewVPTree(X, metric)
+	idx := make([]int, n*k)
+	dists := make([]float64, n*k)
+	heaps := make([]*neighborHeap, tsne.metricWorkers(metric))
+	for w := range heaps {
+		heaps[w] = &neighborHeap{idx: make([]int, 0, k), dist: make([]float64, 0, k)}
+	}
+	err := parallelFor(ctx, n, len(heaps), func(w, i int) {
+		nbrs, dist := tree.kNearest(X.RawRowView(i), k, i, heaps[w])
+		copy(idx[i*k:(i+1)*k], nbrs)
+		copy(dists[i*k:(i+1)*k], dist)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return idx, dists, nil
+}
+
+// isTreeMetric returns whether a vantage-point tree is known to support the metric, i.e. whether it is one of the
+// metrics provided by this package that satisfy the triangle inequality (or SquaredEuclidean).
+func isTreeMetric(metric Metric) bool {
+
+	m, ok := metric.(builtinMetric)
+	return ok && (m == squaredEuclidean || m == euclidean || m == manhattan || m == chebyshev || m == hamming)
+}
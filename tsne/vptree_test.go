@@ -0,0 +1,140 @@
+// Copyright (c) 2018 Daniel Augusto Rizzi Salvadori. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found in the LICENSE file.
+
+package tsne
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"testing"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+// TestVPTree verifies the nearest neighbor and radius queries of the vantage-point tree against exhaustive search.
+func TestVPTree(t *testing.T) {
+
+	X := randomData(200, 3)
+	// Hamming distances are compared on rounded data, with many ties, so only the distances are compared
+	rounded := mat.DenseCopyOf(X)
+	rounded.Apply(func(i, j int, v float64) float64 {
+		return math.Round(v)
+	}, rounded)
+	queries := randomData(20, 3)
+	for _, c := range []struct {
+		name   string
+		X      *mat.Dense
+		metric Metric
+	}{
+		{"squared euclidean", X, SquaredEuclidean},
+		{"euclidean", X, Euclidean},
+		{"manhattan", X, Manhattan},
+		{"chebyshev", X, Chebyshev},
+		{"hamming", rounded, Hamming},
+	} {
+		tree := NewVPTree(c.X, c.metric)
+		n, _ := c.X.Dims()
+		for q := 0; q < 20; q++ {
+			query := append([]float64(nil), queries.RawRowView(q)...)
+			if c.metric == Hamming {
+				for k := range query {
+					query[k] = math.Round(query[k])
+				}
+			}
+			all := make([]float64, n)
+			for i := range all {
+				all[i] = c.metric.Distance(query, c.X.RawRowView(i))
+			}
+			sorted := append([]float64(nil), all...)
+			sort.Float64s(sorted)
+			idx, dist := tree.KNearest(query, 10)
+			if !floats.Equal(dist, sorted[:10]) {
+				t.Fatalf("%s: nearest distances are %v, expected %v", c.name, dist, sorted[:10])
+			}
+			for e, i := range idx {
+				if all[i] != dist[e] {
+					t.Fatalf("%s: neighbor %d is at distance %v, expected %v", c.name, i, all[i], dist[e])
+				}
+			}
+			r := sorted[30]
+			idx, dist = tree.Radius(query, r)
+			if count := sort.SearchFloat64s(sorted, math.Nextafter(r, math.Inf(1))); len(idx) != count || !floats.Equal(dist, sorted[:count]) {
+				t.Fatalf("%s: found %d points within %v, expected %d", c.name, len(idx), r, count)
+			}
+		}
+	}
+	if idx, _ := NewVPTree(X, nil).KNearest(X.RawRowView(0), 300); len(idx) != 200 || idx[0] != 0 {
+		t.Error("expected all the points, starting with the query itself")
+	}
+}
+
+// TestVPTreeNeighbors verifies that the neighbors found with the vantage-point tree match those found by exhaustive search.
+func TestVPTreeNeighbors(t *testing.T) {
+
+	X := randomData(100, 4)
+	tsne := NewTSNE(2, 5, 100, 1, false)
+	tsne.n = 100
+	idx, dist, err := tsne.sparseDataNeighbors(context.Background(), X)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedIdx, expectedDist, _ := nearestNeighbors(context.Background(), 100, 15, func(i, j int) float64 {
+		return sqDist(X.RawRowView(i), X.RawRowView(j))
+	})
+	for e := range idx {
+		if idx[e] != expectedIdx[e] || dist[e] != expectedDist[e] {
+			t.Fatalf("neighbor %d of point %d is %d at %v, expected %d at %v", e%15, e/15, idx[e], dist[e], expectedIdx[e], expectedDist[e])
+		}
+	}
+}
+
+// TestVPTreeCustomMetric verifies that NeighborsVPTree uses the tree with a custom metric, finding the same neighbors
+// as exhaustive search, while the builtin metrics that do not satisfy the triangle inequality are rejected.
+func TestVPTreeCustomMetric(t *testing.T) {
+
+	X := randomData(300, 3)
+	neighbors := func(method NeighborMethod) ([]int, []float64, int) {
+		var calls int
+		cfg := DefaultConfig()
+		cfg.Perplexity = 5
+		cfg.Sparse = true
+		cfg.Neighbors = method
+		cfg.Metric = MetricFunc(func(a, b []float64) float64 {
+			calls++
+			return Manhattan.Distance(a, b)
+		})
+		tsne, err := New(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tsne.n = 300
+		idx, dist, err := tsne.sparseDataNeighbors(context.Background(), X)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return idx, dist, calls
+	}
+	idx, dist, calls := neighbors(NeighborsVPTree)
+	expectedIdx, expectedDist, exhaustiveCalls := neighbors(NeighborsAuto)
+	if !floats.Equal(dist, expectedDist) {
+		t.Error("the tree found different neighbors than exhaustive search")
+	}
+	for e := range idx {
+		if dist[e] != Manhattan.Distance(X.RawRowView(e/15), X.RawRowView(idx[e])) || expectedIdx[e] == e/15 {
+			t.Fatalf("neighbor %d of point %d is %d at %v", e%15, e/15, idx[e], dist[e])
+		}
+	}
+	if calls >= exhaustiveCalls {
+		t.Errorf("the tree computed %d distances, no less than the %d of exhaustive search", calls, exhaustiveCalls)
+	}
+	cfg := DefaultConfig()
+	cfg.Neighbors = NeighborsVPTree
+	cfg.Metric = Cosine
+	if _, err := New(cfg); !errors.Is(err, ErrConfig) || !strings.Contains(err.Error(), "got cosine") {
+		t.Errorf("expected ErrConfig naming the cosine distance, got %v", err)
+	}
+}